@@ -40,6 +40,28 @@ func LoadConfig(path string) error {
 			err = unErr
 			return
 		}
+		// 补默认值并校验线路参数
+		for i := range cfg.SerialProxy.Ports {
+			p := &cfg.SerialProxy.Ports[i]
+			p.normalize()
+			if vErr := p.Validate(); vErr != nil {
+				err = fmt.Errorf("port %s: %w", p.Name, vErr)
+				return
+			}
+		}
+		if api := cfg.SerialProxy.LocalAPI; api != nil {
+			if vErr := api.Validate(); vErr != nil {
+				err = vErr
+				return
+			}
+		}
+		if vErr := validateSniffers(&cfg.SerialProxy); vErr != nil {
+			err = vErr
+			return
+		}
+		if cfg.SerialProxy.LockDir == "" {
+			cfg.SerialProxy.LockDir = DefaultLockDir
+		}
 		SerialCfg = &cfg.SerialProxy
 
 		// 构建 portMap
@@ -94,3 +116,60 @@ func GetPort(name string) (Port, bool) {
 	p, ok := portMap[name]
 	return p, ok
 }
+
+// StatusTopic 返回端口状态事件的上报主题
+func StatusTopic(port string) string {
+	return fmt.Sprintf("edgex/service/status/device_uart/%s", port)
+}
+
+// ControlTopic 返回端口控制命令的下行主题
+func ControlTopic(port string) string {
+	return fmt.Sprintf("edgex/service/command/control/device_uart/%s", port)
+}
+
+// validateSniffers 补默认标签，并检查监听端口存在、互不重复且不参与任何会写入的路径
+func validateSniffers(c *SerialProxyConfig) error {
+	ports := make(map[string]*Port, len(c.Ports))
+	for i := range c.Ports {
+		ports[c.Ports[i].Name] = &c.Ports[i]
+	}
+	bound := make(map[string]bool, len(c.Bindings))
+	for _, b := range c.Bindings {
+		bound[b.PortName] = true
+	}
+	used := make(map[string]string)
+	for i := range c.Sniffers {
+		s := &c.Sniffers[i]
+		if s.Name == "" {
+			return fmt.Errorf("sniffer needs a name")
+		}
+		if s.LabelA == "" {
+			s.LabelA = "A"
+		}
+		if s.LabelB == "" {
+			s.LabelB = "B"
+		}
+		if s.PortA == s.PortB {
+			return fmt.Errorf("sniffer %s: portA and portB must differ", s.Name)
+		}
+		for _, name := range []string{s.PortA, s.PortB} {
+			p, ok := ports[name]
+			if !ok {
+				return fmt.Errorf("sniffer %s: unknown port %q", s.Name, name)
+			}
+			if other, dup := used[name]; dup {
+				return fmt.Errorf("sniffer %s: port %s already used by sniffer %s", s.Name, name, other)
+			}
+			used[name] = s.Name
+			if bound[name] || p.Share != nil || len(p.Fanout) > 0 || p.AutoBaud != nil {
+				return fmt.Errorf("sniffer %s: port %s must not have bindings, share, fanout or autoBaud", s.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// SnifferTopic 返回监听结果的上报主题
+func SnifferTopic(name string) string {
+	return fmt.Sprintf("edgex/service/data/device_uart/sniff/%s", name)
+}
@@ -0,0 +1,333 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+)
+
+// standardBauds 是 tarm/serial 支持的固定波特率表
+var standardBauds = map[int]bool{
+	50: true, 75: true, 110: true, 134: true, 150: true, 200: true, 300: true,
+	600: true, 1200: true, 1800: true, 2400: true, 4800: true, 9600: true,
+	19200: true, 38400: true, 57600: true, 115200: true, 230400: true,
+	460800: true, 500000: true, 576000: true, 921600: true, 1000000: true,
+	1152000: true, 1500000: true, 2000000: true, 2500000: true, 3000000: true,
+	3500000: true, 4000000: true,
+}
+
+// normalize 为未填写的线路参数补默认值（8N1，无流控）
+func (p *Port) normalize() {
+	if p.DataBits == 0 {
+		p.DataBits = 8
+	}
+	if p.Parity == "" {
+		p.Parity = ParityNone
+	}
+	if p.StopBits == 0 {
+		p.StopBits = 1
+	}
+	if p.FlowControl == "" {
+		p.FlowControl = FlowNone
+	}
+	if p.Backend == "" {
+		p.Backend = BackendTarm
+	}
+	if p.ReconnectMinMs == 0 {
+		p.ReconnectMinMs = 500
+	}
+	if p.ReconnectMaxMs == 0 {
+		p.ReconnectMaxMs = 30000
+	}
+	if p.StatsIntervalMs == 0 {
+		p.StatsIntervalMs = 10000
+	}
+	if p.EchoCancel && p.EchoWindowMs == 0 {
+		p.EchoWindowMs = 50
+	}
+	if a := p.AutoBaud; a != nil {
+		if len(a.Candidates) == 0 {
+			a.Candidates = append([]LineCandidate(nil), DefaultBaudCandidates...)
+		}
+		if a.DwellMs == 0 {
+			a.DwellMs = 2000
+		}
+		if a.MinFrames == 0 {
+			a.MinFrames = 1
+		}
+	}
+	if p.Share != nil {
+		if p.Share.Commands == "" {
+			p.Share.Commands = SharePause
+		}
+		if p.Share.Parser == "" {
+			p.Share.Parser = ShareCoexist
+		}
+	}
+	if p.Type == "rs485" {
+		if p.RS485Mode == "" {
+			p.RS485Mode = RS485GPIO
+		}
+		if p.RTSOnSend == "" {
+			p.RTSOnSend = "high"
+		}
+	}
+}
+
+// Validate 检查线路参数是否合法，非法组合在加载配置时即被拒绝
+func (p *Port) Validate() error {
+	switch {
+	case p.remote():
+		if p.Device != "" || p.Match != nil {
+			return fmt.Errorf("device/match are not valid for type %s, use address", p.Type)
+		}
+		if _, _, err := net.SplitHostPort(p.Address); err != nil {
+			return fmt.Errorf("invalid address %q: %w", p.Address, err)
+		}
+	case p.Address != "":
+		return fmt.Errorf("address is only valid for type tcp/rfc2217")
+	case p.Type == "pty" && p.Match != nil:
+		return fmt.Errorf("match is not valid for type pty")
+	case p.Type == "pty":
+		// 伪终端由服务自行分配，device 仅用作可选的符号链接
+	case p.Match != nil && p.Device != "":
+		return fmt.Errorf("device and match are mutually exclusive")
+	case p.Match != nil && p.Match.String() == "":
+		return fmt.Errorf("match needs at least one of vid/pid/serial/interface/path")
+	case p.Match == nil && p.Device == "":
+		return fmt.Errorf("device or match is required")
+	}
+	// 伪终端不按波特率限速，原始 TCP 的线路参数在串口服务器上配置，二者的波特率可以不填
+	if p.Baudrate < 0 || p.Baudrate == 0 && p.Type != "pty" && p.Type != "tcp" {
+		return fmt.Errorf("invalid baudrate %d", p.Baudrate)
+	}
+	if p.DataBits < 5 || p.DataBits > 8 {
+		return fmt.Errorf("invalid dataBits %d, want 5..8", p.DataBits)
+	}
+	switch p.Parity {
+	case ParityNone, ParityOdd, ParityEven, ParityMark, ParitySpace:
+	default:
+		return fmt.Errorf("invalid parity %q", p.Parity)
+	}
+	switch p.StopBits {
+	case 1:
+	case 1.5:
+		// 内核中 CSTOPB + CS5 即 1.5 停止位
+		if p.DataBits != 5 {
+			return fmt.Errorf("stopBits 1.5 requires dataBits 5, got %d", p.DataBits)
+		}
+	case 2:
+		if p.DataBits == 5 {
+			return fmt.Errorf("stopBits 2 is not available with dataBits 5")
+		}
+	default:
+		return fmt.Errorf("invalid stopBits %v", p.StopBits)
+	}
+	switch p.FlowControl {
+	case FlowNone, FlowXONXOFF:
+	case FlowRTSCTS:
+		// RS-485 总线没有 RTS/CTS 握手线
+		if p.Type == "rs485" {
+			return fmt.Errorf("flowControl rtscts is not supported on rs485")
+		}
+	default:
+		return fmt.Errorf("invalid flowControl %q", p.FlowControl)
+	}
+	if p.EchoWindowMs < 0 {
+		return fmt.Errorf("invalid echoWindowMs %d", p.EchoWindowMs)
+	}
+	if p.ReconnectMinMs < 0 || p.ReconnectMaxMs < p.ReconnectMinMs {
+		return fmt.Errorf("invalid reconnect backoff %d..%d ms", p.ReconnectMinMs, p.ReconnectMaxMs)
+	}
+	if p.StatsIntervalMs < -1 {
+		return fmt.Errorf("invalid statsIntervalMs %d", p.StatsIntervalMs)
+	}
+	if p.ErrorRateThreshold < 0 {
+		return fmt.Errorf("invalid errorRateThreshold %v", p.ErrorRateThreshold)
+	}
+	if err := p.validateRS485(); err != nil {
+		return err
+	}
+	if err := p.validateShare(); err != nil {
+		return err
+	}
+	if err := p.validateAutoBaud(); err != nil {
+		return err
+	}
+	if p.remote() || p.Type == "pty" {
+		// 串口由远端服务器打开或是伪终端，本地驱动选项不适用
+		return nil
+	}
+	return p.validateBackend()
+}
+
+// remote 判断端口是否经网络连接远端串口服务器
+func (p *Port) remote() bool {
+	return p.Type == "tcp" || p.Type == "rfc2217"
+}
+
+// validateRS485 检查 RS-485 方向控制相关选项
+func (p *Port) validateRS485() error {
+	if p.Type != "rs485" {
+		if p.RS485Mode != "" {
+			return fmt.Errorf("rs485Mode is only valid for type rs485")
+		}
+		return nil
+	}
+	switch p.RS485Mode {
+	case RS485GPIO, RS485Kernel:
+	default:
+		return fmt.Errorf("invalid rs485Mode %q", p.RS485Mode)
+	}
+	if p.RTSOnSend != "high" && p.RTSOnSend != "low" {
+		return fmt.Errorf("invalid rtsOnSend %q, want high/low", p.RTSOnSend)
+	}
+	if p.DelayBeforeSendMs < 0 || p.DelayAfterSendMs < 0 {
+		return fmt.Errorf("rs485 delays must not be negative")
+	}
+	if p.RS485Mode != RS485GPIO {
+		return nil
+	}
+	switch {
+	case p.DE != nil && p.DEPin != nil:
+		return fmt.Errorf("de and dePin are mutually exclusive")
+	case p.DE == nil && p.DEPin == nil:
+		return fmt.Errorf("rs485Mode gpio needs de (gpiochip) or dePin (sysfs)")
+	case p.DEPin != nil && *p.DEPin < 0:
+		return fmt.Errorf("invalid dePin %d", *p.DEPin)
+	case p.RE != nil && p.DE == nil:
+		return fmt.Errorf("re needs de to be configured via gpiochip")
+	}
+	for _, l := range []*GPIOLine{p.DE, p.RE} {
+		if l == nil {
+			continue
+		}
+		if l.Chip == "" || l.Line < 0 {
+			return fmt.Errorf("invalid gpio line %s:%d", l.Chip, l.Line)
+		}
+	}
+	return nil
+}
+
+// validateShare 检查 TCP 共享配置
+func (p *Port) validateShare() error {
+	s := p.Share
+	if s == nil {
+		return nil
+	}
+	if s.Listen == "" && s.MonitorListen == "" && s.RFC2217Listen == "" {
+		return fmt.Errorf("share needs listen, monitorListen or rfc2217Listen")
+	}
+	// 共享客户端可以直接写串口或修改线路参数，不允许对任意来源开放
+	if len(s.Allow) == 0 {
+		return fmt.Errorf("share requires an allow list")
+	}
+	for _, a := range s.Allow {
+		if _, err := ParseAllow(a); err != nil {
+			return err
+		}
+	}
+	for _, addr := range []string{s.Listen, s.MonitorListen, s.RFC2217Listen} {
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid share address %q: %w", addr, err)
+		}
+	}
+	for _, mode := range []string{s.Commands, s.Parser} {
+		if mode != SharePause && mode != ShareCoexist {
+			return fmt.Errorf("invalid share mode %q, want pause/coexist", mode)
+		}
+	}
+	return nil
+}
+
+// validateAutoBaud 检查自动检测配置，每个组合都须是本端口可用的线路参数
+func (p *Port) validateAutoBaud() error {
+	a := p.AutoBaud
+	if a == nil {
+		return nil
+	}
+	switch p.Type {
+	case "uart", "rs485", "rs232":
+	default:
+		return fmt.Errorf("autoBaud is only valid for uart/rs485/rs232")
+	}
+	if a.DwellMs < 0 || a.MinFrames < 0 {
+		return fmt.Errorf("autoBaud dwellMs/minFrames must not be negative")
+	}
+	if _, err := hex.DecodeString(a.Probe); err != nil {
+		return fmt.Errorf("invalid autoBaud probe %q: %w", a.Probe, err)
+	}
+	for _, c := range a.Candidates {
+		q := p.WithCandidate(c)
+		q.AutoBaud = nil
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("autoBaud candidate %s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// WithCandidate 返回套用了候选组合的端口配置副本，候选中未填写的字段沿用原配置
+func (p *Port) WithCandidate(c LineCandidate) Port {
+	q := *p
+	if c.Baudrate != 0 {
+		q.Baudrate = c.Baudrate
+	}
+	if c.DataBits != 0 {
+		q.DataBits = c.DataBits
+	}
+	if c.Parity != "" {
+		q.Parity = c.Parity
+	}
+	if c.StopBits != 0 {
+		q.StopBits = c.StopBits
+	}
+	return q
+}
+
+// String 以 9600 8/even/1 形式描述候选组合
+func (c LineCandidate) String() string {
+	return fmt.Sprintf("%d %d/%s/%v", c.Baudrate, c.DataBits, c.Parity, c.StopBits)
+}
+
+// validateBackend 检查所选底层驱动是否支持配置的选项
+func (p *Port) validateBackend() error {
+	switch p.Backend {
+	case BackendTarm:
+		if !standardBauds[p.Baudrate] {
+			return fmt.Errorf("baudrate %d needs backend termios", p.Baudrate)
+		}
+		if p.InterCharTimeoutMs != 0 || p.MinRead != 0 || p.LowLatency {
+			return fmt.Errorf("interCharTimeoutMs/minRead/lowLatency need backend termios")
+		}
+	case BackendTermios:
+		if p.MinRead < 0 || p.MinRead > 255 {
+			return fmt.Errorf("invalid minRead %d, want 0..255", p.MinRead)
+		}
+		if p.InterCharTimeoutMs < 0 || p.InterCharTimeoutMs > 25500 {
+			return fmt.Errorf("invalid interCharTimeoutMs %d, want 0..25500", p.InterCharTimeoutMs)
+		}
+	default:
+		return fmt.Errorf("invalid backend %q", p.Backend)
+	}
+	return nil
+}
+
+// ParseAllow 把 IP 或 CIDR 形式的允许项解析为网段，单个 IP 视为 /32（IPv6 为 /128）
+func ParseAllow(a string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(a); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(a)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid allow entry %q, want IP or CIDR", a)
+	}
+	bits := 8 * net.IPv6len
+	if v4 := ip.To4(); v4 != nil {
+		ip, bits = v4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -1,15 +1,174 @@
 package config
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Port 描述一个串口设备
 type Port struct {
-	Name      string `yaml:"name"`      // 逻辑名称
-	Device    string `yaml:"device"`    // 串口设备节点
-	Type      string `yaml:"type"`      // uart/rs485/rs232
-	Baudrate  int    `yaml:"baudrate"`  // 波特率
-	DEPin     int    `yaml:"dePin"`     // RS-485 DE/RE 控制 GPIO 编号
-	TimeoutMs int    `yaml:"timeoutMs"` // 读操作超时（毫秒）
+	Name        string  `yaml:"name"`        // 逻辑名称
+	Device      string  `yaml:"device"`      // 串口设备节点（与 match 二选一）；pty 类型下为可选的从端符号链接路径
+	Type        string  `yaml:"type"`        // uart/rs485/rs232/pty/tcp/rfc2217
+	Baudrate    int     `yaml:"baudrate"`    // 波特率
+	DataBits    int     `yaml:"dataBits"`    // 数据位 5/6/7/8，默认 8
+	Parity      string  `yaml:"parity"`      // 校验 none/odd/even/mark/space，默认 none
+	StopBits    float64 `yaml:"stopBits"`    // 停止位 1/1.5/2，默认 1（1.5 仅限 5 数据位）
+	FlowControl string  `yaml:"flowControl"` // 流控 none/rtscts/xonxoff，默认 none
+	DEPin       *int    `yaml:"dePin"`       // RS-485 DE/RE 控制 GPIO 编号（sysfs，旧方式），0 是合法编号，未配置为 nil
+	TimeoutMs   int     `yaml:"timeoutMs"`   // 已不使用：读取由轮询器驱动，有数据即返回；保留以兼容旧配置
+
+	Backend            string `yaml:"backend"`            // 底层驱动 tarm/termios，默认 tarm
+	InterCharTimeoutMs int    `yaml:"interCharTimeoutMs"` // 字符间超时（毫秒，按 VTIME 语义聚合读取，仅 termios）
+	MinRead            int    `yaml:"minRead"`            // 一次读取至少返回的字节数（按 VMIN 语义，仅 termios）
+	LowLatency         bool   `yaml:"lowLatency"`         // 设置 ASYNC_LOW_LATENCY（仅 termios）
+	Exclusive          bool   `yaml:"exclusive"`          // 打开后设置 TIOCEXCL，阻止其他进程再打开该 tty
+
+	RS485Mode         string    `yaml:"rs485Mode"`         // RS-485 方向控制 gpio/kernel，默认 gpio
+	RTSOnSend         string    `yaml:"rtsOnSend"`         // kernel 模式下发送时 RTS 电平 high/low，默认 high
+	DelayBeforeSendMs int       `yaml:"delayBeforeSendMs"` // 使能驱动器到开始发送的延时（毫秒）
+	DelayAfterSendMs  int       `yaml:"delayAfterSendMs"`  // 发送结束到切回接收的延时（毫秒）
+	RxDuringTx        bool      `yaml:"rxDuringTx"`        // 发送期间保持接收
+	DE                *GPIOLine `yaml:"de"`                // gpio 模式下的 DE 引脚（gpiochip 字符设备）
+	RE                *GPIOLine `yaml:"re"`                // gpio 模式下可选的独立 /RE 引脚
+
+	EchoCancel   bool `yaml:"echoCancel"`   // 剥离本端发送产生的回显
+	EchoWindowMs int  `yaml:"echoWindowMs"` // 发送完成后等待回显的窗口（毫秒），默认 50
+	DetectBreak  bool `yaml:"detectBreak"`  // 以 PARMRK 标记接收到的 BREAK 并作为独立事件上报
+
+	StatsIntervalMs    int     `yaml:"statsIntervalMs"`    // 驱动计数（TIOCGICOUNT）与帧内字节间隔上报间隔（毫秒），默认 10000，-1 关闭
+	ErrorRateThreshold float64 `yaml:"errorRateThreshold"` // 区间内 错误数/接收字节数 超过该值时告警，0 不告警
+
+	ReconnectMinMs int `yaml:"reconnectMinMs"` // 设备失效后首次重新打开的等待时间（毫秒），默认 500
+	ReconnectMaxMs int `yaml:"reconnectMaxMs"` // 指数退避的上限（毫秒），默认 30000
+
+	Match *USBMatch `yaml:"match"` // 按 USB 属性匹配设备，每次打开时解析为 /dev 节点
+
+	Address string `yaml:"address"` // tcp/rfc2217 类型：串口服务器地址 host:port
+
+	Share  *ShareConfig `yaml:"share"`  // 通过 TCP 共享本端口（ser2net 方式）
+	Fanout []FanoutPTY  `yaml:"fanout"` // 为只能打开 tty 的本地程序创建的 pty
+
+	AutoBaud *AutoBaudConfig `yaml:"autoBaud"` // 启动时自动检测波特率与帧格式
+}
+
+// AutoBaudConfig 描述波特率/帧格式自动检测：依次尝试各组合，按绑定协议成功解析的帧数评分
+type AutoBaudConfig struct {
+	Candidates []LineCandidate `yaml:"candidates"` // 依次尝试的组合，为空时使用 DefaultBaudCandidates
+	DwellMs    int             `yaml:"dwellMs"`    // 每个组合的监听时长（毫秒），默认 2000
+	Probe      string          `yaml:"probe"`      // 可选，切换到每个组合后发送的探测帧（十六进制）
+	MinFrames  int             `yaml:"minFrames"`  // 采用检测结果所需的最少帧数，默认 1
+	Persist    string          `yaml:"persist"`    // 可选，保存检测结果的文件，下次检测时优先尝试
+}
+
+// LineCandidate 是自动检测中的一个波特率/帧格式组合，未填写的字段沿用端口配置
+type LineCandidate struct {
+	Baudrate int     `yaml:"baudrate" json:"baudrate"`
+	DataBits int     `yaml:"dataBits" json:"dataBits"`
+	Parity   string  `yaml:"parity" json:"parity"`
+	StopBits float64 `yaml:"stopBits" json:"stopBits"`
+}
+
+// DefaultBaudCandidates 是电表等设备常见的出厂组合
+var DefaultBaudCandidates = []LineCandidate{
+	{Baudrate: 9600, Parity: ParityNone}, {Baudrate: 9600, Parity: ParityEven},
+	{Baudrate: 2400, Parity: ParityEven}, {Baudrate: 1200, Parity: ParityEven},
+	{Baudrate: 4800, Parity: ParityEven}, {Baudrate: 19200, Parity: ParityNone},
+	{Baudrate: 38400, Parity: ParityNone}, {Baudrate: 115200, Parity: ParityNone},
+}
+
+// FanoutPTY 描述扇出中的一个 pty
+type FanoutPTY struct {
+	Link string `yaml:"link"` // 可选，创建指向 pty 从端的符号链接
+}
+
+// ShareConfig 描述端口的 TCP 共享：一个独占写入客户端（原始 TCP 或 RFC 2217）与任意只读监视客户端
+type ShareConfig struct {
+	Listen        string   `yaml:"listen"`        // 独占写入客户端监听地址，如 :7001
+	MonitorListen string   `yaml:"monitorListen"` // 只读监视客户端监听地址，如 :7101
+	RFC2217Listen string   `yaml:"rfc2217Listen"` // RFC 2217 客户端监听地址，可远程修改线路参数，会话结束后恢复
+	Allow         []string `yaml:"allow"`         // 允许连接的客户端 IP 或网段（CIDR），对所有监听地址生效，必填
+	Commands      string   `yaml:"commands"`      // 写入客户端在线时 MQTT 命令 pause/coexist，默认 pause
+	Parser        string   `yaml:"parser"`        // 写入客户端在线时协议解析 pause/coexist，默认 coexist
 }
 
+// USBMatch 按 USB 属性定位串口，未填写的字段不参与匹配
+type USBMatch struct {
+	VID       string `yaml:"vid"`       // idVendor，十六进制，如 0403
+	PID       string `yaml:"pid"`       // idProduct，十六进制，如 6001
+	Serial    string `yaml:"serial"`    // iSerial 字符串
+	Interface *int   `yaml:"interface"` // bInterfaceNumber，多口适配器用于区分各口
+	Path      string `yaml:"path"`      // 物理总线路径，如 1-1.2（USB 设备）或 1-1.2:1.0（接口）
+}
+
+// String 以 vid=0403,pid=6001 形式描述匹配条件
+func (m *USBMatch) String() string {
+	var parts []string
+	add := func(k, v string) {
+		if v != "" {
+			parts = append(parts, k+"="+v)
+		}
+	}
+	add("vid", m.VID)
+	add("pid", m.PID)
+	add("serial", m.Serial)
+	if m.Interface != nil {
+		add("interface", strconv.Itoa(*m.Interface))
+	}
+	add("path", m.Path)
+	return strings.Join(parts, ",")
+}
+
+// DeviceLabel 返回用于日志的设备描述：固定节点或 USB 匹配条件
+func (p *Port) DeviceLabel() string {
+	if p.Match != nil {
+		return "usb[" + p.Match.String() + "]"
+	}
+	return p.Device
+}
+
+// GPIOLine 通过 gpiochip 字符设备（v2 uAPI）定位一个 GPIO 线
+type GPIOLine struct {
+	Chip      string `yaml:"chip"`      // gpiochip 名称或路径，如 gpiochip0
+	Line      int    `yaml:"line"`      // 线偏移
+	ActiveLow bool   `yaml:"activeLow"` // 低电平有效
+}
+
+// RS-485 方向控制方式
+const (
+	RS485GPIO   = "gpio"   // 用户态翻转 DE/RE GPIO
+	RS485Kernel = "kernel" // 由驱动通过 TIOCSRS485 自动切换
+)
+
+// 共享写入客户端在线时 MQTT 命令/协议解析的行为
+const (
+	SharePause   = "pause"   // 暂停
+	ShareCoexist = "coexist" // 照常进行
+)
+
+// 底层串口驱动
+const (
+	BackendTarm    = "tarm"
+	BackendTermios = "termios"
+)
+
+// 校验方式
+const (
+	ParityNone  = "none"
+	ParityOdd   = "odd"
+	ParityEven  = "even"
+	ParityMark  = "mark"
+	ParitySpace = "space"
+)
+
+// 流控方式
+const (
+	FlowNone    = "none"
+	FlowRTSCTS  = "rtscts"
+	FlowXONXOFF = "xonxoff"
+)
+
 // 一种协议对应的 MQTT 主题
 type Protocol struct {
 	ID            string // 协议标识符
@@ -25,8 +184,46 @@ type Binding struct {
 
 // SerialProxyConfig 汇总了 Ports、Protocols、Bindings 等
 type SerialProxyConfig struct {
-	Ports           []Port     `yaml:"Ports"`
-	Protocols       []Protocol `yaml:"Protocols"`
-	Bindings        []Binding  `yaml:"Bindings"`
-	DefaultProtocol string     `yaml:"DefaultProtocol"`
+	Ports           []Port          `yaml:"Ports"`
+	Protocols       []Protocol      `yaml:"Protocols"`
+	Bindings        []Binding       `yaml:"Bindings"`
+	DefaultProtocol string          `yaml:"DefaultProtocol"`
+	LocalAPI        *LocalAPIConfig `yaml:"LocalAPI"`
+	Sniffers        []Sniffer       `yaml:"Sniffers"`
+	LockDir         string          `yaml:"LockDir"` // UUCP 锁文件（LCK..ttyXXX）目录，默认 /var/lock，none 关闭
+}
+
+// UUCP 锁文件目录
+const (
+	DefaultLockDir = "/var/lock"
+	LockNone       = "none" // 不创建也不检查锁文件
+)
+
+// Sniffer 把两个端口作为同一条链路的两个方向被动监听，从不向任一端口写入
+type Sniffer struct {
+	Name        string   `yaml:"name"`
+	PortA       string   `yaml:"portA"`       // 方向 A 的端口（如接主站 TX）
+	PortB       string   `yaml:"portB"`       // 方向 B 的端口（如接从站 TX）
+	LabelA      string   `yaml:"labelA"`      // 方向 A 的标签，默认 A
+	LabelB      string   `yaml:"labelB"`      // 方向 B 的标签，默认 B
+	Protocols   []string `yaml:"protocols"`   // 每个方向各自运行的协议解析器，默认 DefaultProtocol
+	CaptureFile string   `yaml:"captureFile"` // 可选，按到达顺序追加写入的抓包文件
+}
+
+// LocalAPIConfig 描述本地 Unix 套接字接口，对端身份由 SO_PEERCRED 校验
+type LocalAPIConfig struct {
+	Socket    string   `yaml:"socket"`    // 套接字路径，如 /run/device_uart/api.sock
+	AllowUIDs []uint32 `yaml:"allowUids"` // 允许的对端 uid
+	AllowGIDs []uint32 `yaml:"allowGids"` // 允许的对端 gid（主组）
+}
+
+// Validate 检查本地接口配置
+func (c *LocalAPIConfig) Validate() error {
+	if c.Socket == "" {
+		return fmt.Errorf("LocalAPI.socket is required")
+	}
+	if len(c.AllowUIDs) == 0 && len(c.AllowGIDs) == 0 {
+		return fmt.Errorf("LocalAPI needs allowUids and/or allowGids")
+	}
+	return nil
 }
@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/linjuya-lu/device_uart_go/internal/mqttclient"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// modemPollInterval 是驱动不支持 TIOCMIWAIT 时轮询调制解调器线的间隔
+const modemPollInterval = 100 * time.Millisecond
+
+// startModemWatch 为支持调制解调器线的端口启动监视，变化时发布到端口状态主题并分发给 hub 的订阅者
+func startModemWatch(client mqtt.Client, p serial.Port, hub *serial.ModemHub, stop <-chan struct{}) {
+	mc, ok := p.(serial.ModemController)
+	if !ok {
+		return
+	}
+	name := p.Name()
+	serial.WatchModem(mc, modemPollInterval, stop, func(old, cur serial.ModemLine) {
+		fmt.Printf("📶 [%s] modem lines %s → %s\n", name, old, cur)
+		data := map[string]interface{}{
+			"lines":   cur.Map(),
+			"changed": (old ^ cur).String(),
+		}
+		if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "modem", data); err != nil {
+			fmt.Printf("❌ publish modem status failed: %v\n", err)
+		}
+		hub.Publish(old, cur)
+	})
+}
+
+// publishBreak 上报收到的 BREAK 事件
+func publishBreak(client mqtt.Client, name string) {
+	fmt.Printf("⚡ [%s] BREAK received\n", name)
+	if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "break", nil); err != nil {
+		fmt.Printf("❌ publish break status failed: %v\n", err)
+	}
+}
+
+// subscribeControl 订阅每个端口的控制主题，执行收到的控制命令并上报结果
+func subscribeControl(client mqtt.Client, portMap map[string]*serial.Supervisor, writers map[string]*portWriter) {
+	for name, sup := range portMap {
+		topic := config.ControlTopic(name)
+		sup, w := sup, writers[name]
+		token := client.Subscribe(topic, 0, func(_ mqtt.Client, msg mqtt.Message) {
+			var cp mqttclient.ControlPayload
+			if err := mqttclient.UnmarshalPayload(msg.Payload(), &cp); err != nil {
+				fmt.Printf("❌ control topic=%s: %v\n", msg.Topic(), err)
+				return
+			}
+			fmt.Printf("▶ Got control: port=%s action=%s\n", sup.Name(), cp.Action)
+			result := map[string]interface{}{"action": cp.Action, "ok": true}
+			var data interface{}
+			err := serial.ErrPortDown
+			switch {
+			case cp.Action == "detectLine":
+				// 断开时检测器会等到重新连接后开始，结果以 autoBaud 事件上报
+				err = w.baud.Start()
+			case cp.Action == "reconfigure":
+				data, err = reconfigurePort(client, w, cp.Settings)
+			case sup.Connected():
+				data, err = handleControl(sup.Port(), cp)
+			}
+			if err != nil {
+				fmt.Printf("❌ control %s on %s failed: %v\n", cp.Action, sup.Name(), err)
+				result["ok"] = false
+				result["error"] = err.Error()
+			}
+			if data != nil {
+				result["result"] = data
+			}
+			if err := mqttclient.PublishPortStatus(client, config.StatusTopic(sup.Name()), sup.Name(), "control", result); err != nil {
+				fmt.Printf("❌ publish control result failed: %v\n", err)
+			}
+		})
+		token.Wait()
+		if token.Error() != nil {
+			fmt.Printf("❌ 订阅 topic=%s 失败: %v\n", topic, token.Error())
+		} else {
+			fmt.Printf("✅ Successfully subscribed to topic=%s\n", topic)
+		}
+	}
+}
+
+// reconfigurePort 在不重新打开端口的情况下修改线路参数（MQTT 控制主题与 REST 共用）：
+// patch 为 JSON，未给出的字段沿用当前值，为空时只返回当前参数。
+// 与写入互斥，不会在一帧发送到一半时切换；读循环的解析缓存和各类订阅保持不变，
+// 修改成功后发布 line 事件，之后的重新打开也沿用新参数
+func reconfigurePort(client mqtt.Client, w *portWriter, patch json.RawMessage) (serial.LineSettings, error) {
+	name := w.sup.Name()
+	// 先取得写入锁再读取当前参数，保证补丁基于的参数在应用前不会被其他路径改掉
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	lc, ok := w.sup.Port().(serial.LineConfigurer)
+	if !ok {
+		return serial.LineSettings{}, fmt.Errorf("port %s cannot change line settings", name)
+	}
+	if len(patch) == 0 {
+		return lc.LineSettings(), nil
+	}
+	if w.baud.Active() {
+		return serial.LineSettings{}, serial.ErrDetecting
+	}
+	if !w.sup.Connected() {
+		return serial.LineSettings{}, serial.ErrPortDown
+	}
+	ls := lc.LineSettings()
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&ls); err != nil {
+		return serial.LineSettings{}, fmt.Errorf("invalid line settings: %w", err)
+	}
+	if err := lc.SetLineSettings(ls); err != nil {
+		return serial.LineSettings{}, err
+	}
+	cur := lc.LineSettings()
+	if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "line", cur); err != nil {
+		fmt.Printf("❌ publish line settings failed: %v\n", err)
+	}
+	return cur, nil
+}
+
+// handleControl 执行一条控制命令，返回需要随结果上报的数据
+func handleControl(p serial.Port, cp mqttclient.ControlPayload) (interface{}, error) {
+	switch cp.Action {
+	case "getLines", "setLine", "pulseLine":
+		mc, ok := p.(serial.ModemController)
+		if !ok {
+			return nil, serial.ErrModemUnsupported
+		}
+		return handleModemControl(mc, cp)
+	case "sendBreak":
+		bs, ok := p.(serial.BreakSender)
+		if !ok {
+			return nil, fmt.Errorf("port %s cannot send break", p.Name())
+		}
+		return nil, bs.SendBreak(time.Duration(cp.DurationMs) * time.Millisecond)
+	default:
+		return nil, fmt.Errorf("unknown action %q", cp.Action)
+	}
+}
+
+// handleModemControl 处理调制解调器线相关的控制命令
+func handleModemControl(mc serial.ModemController, cp mqttclient.ControlPayload) (interface{}, error) {
+	if cp.Action != "getLines" {
+		line, err := serial.ParseModemLine(cp.Line)
+		if err != nil {
+			return nil, err
+		}
+		if cp.Action == "setLine" {
+			err = mc.SetModemLines(line, cp.Value)
+		} else {
+			d := time.Duration(cp.DurationMs) * time.Millisecond
+			if d <= 0 {
+				return nil, fmt.Errorf("pulseLine needs durationMs > 0")
+			}
+			err = serial.PulseModemLine(mc, line, cp.Value, d)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	cur, err := mc.ModemLines()
+	if err != nil {
+		return nil, err
+	}
+	return cur.Map(), nil
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// frameEvent 是读循环解析出的一帧
+type frameEvent struct {
+	Port     string
+	Protocol string
+	Frame    []byte
+	Timing   serial.FrameTiming
+}
+
+// frameSubQueue 是每个订阅者的缓冲帧数，消费过慢时丢弃新帧
+const frameSubQueue = 64
+
+// frameHub 把读循环解析出的帧分发给本地订阅者（Unix 套接字接口等）
+type frameHub struct {
+	mu   sync.Mutex
+	subs map[chan frameEvent]struct{}
+}
+
+func newFrameHub() *frameHub {
+	return &frameHub{subs: make(map[chan frameEvent]struct{})}
+}
+
+// subscribe 注册订阅，返回接收通道和取消函数
+func (h *frameHub) subscribe() (<-chan frameEvent, func()) {
+	ch := make(chan frameEvent, frameSubQueue)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish 非阻塞地投递给所有订阅者
+func (h *frameHub) publish(ev frameEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
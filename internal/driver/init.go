@@ -2,10 +2,12 @@ package driver
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
 	"github.com/linjuya-lu/device_uart_go/internal/config"
 	"github.com/linjuya-lu/device_uart_go/internal/mqttclient"
 	"github.com/linjuya-lu/device_uart_go/internal/serial"
@@ -13,31 +15,90 @@ import (
 
 // InitializeSerialProxy ：
 //  1. 加载配置
-//  2. 打开所有串口
-//  3. 为每个串口启动单协程读循环，支持多协议解析
+//  2. 打开所有串口，由监管器负责断线重开；未插入的适配器在出现后由热插拔事件打开
+//  3. 为每个串口启动单协程读循环，支持多协议解析；每次读取都记录到达时刻，帧以首字节时刻上报
 //  4. 订阅所有协议的命令主题，把收到的命令写到对应串口
-func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
+//  5. 订阅各端口的控制主题；连接状态、调制解调器线变化和驱动计数由状态回调上报
+//  6. 按配置通过 TCP 共享端口，写入客户端在线时按 pause/coexist 处理命令与解析
+//  7. 按配置创建 pty 扇出，本地程序经 pty 与 MQTT 命令共用同一写入路径
+//  8. 按配置开放本地 Unix 套接字接口（列端口、订阅帧、写入与事务）
+//  9. 被动监听的端口只交给监听器读取，不参与命令、控制、共享等任何写入路径
+//  10. 配置了 autoBaud 的端口启动后先检测线路参数，也可经控制主题 detectLine 重新检测
+//  11. 线路参数可经控制主题 reconfigure 或 REST 接口在运行时修改，不重新打开端口；sdk 为 nil 时不注册 REST
+func InitializeSerialProxy(configPath string, mqttClient mqtt.Client, sdk interfaces.DeviceServiceSDK) error {
 	// 1. 载入 YAML
 	if err := config.LoadConfig(configPath); err != nil {
 		return fmt.Errorf("load config: %w", err)
 	}
-	// 2. 打开所有串口并记入 portMap
-	portMap := make(map[string]serial.Port, len(config.SerialCfg.Ports))
+	serial.SetLockDir(config.SerialCfg.LockDir)
+	// 2. 打开所有串口并交给监管器，设备失效后自动重新打开
+	portMap := make(map[string]*serial.Supervisor, len(config.SerialCfg.Ports))
+	shares := make(map[string]*serial.ShareServer, len(config.SerialCfg.Ports))
+	fanouts := make(map[string]*serial.Fanout, len(config.SerialCfg.Ports))
+	writers := make(map[string]*portWriter, len(config.SerialCfg.Ports))
+	// 被动监听的端口单独存放，热插拔仍需覆盖它们
+	taps := make(map[string]*serial.Supervisor)
+	allPorts := make(map[string]*serial.Supervisor, len(config.SerialCfg.Ports))
+	sniffed := make(map[string]bool)
+	for _, sc := range config.SerialCfg.Sniffers {
+		sniffed[sc.PortA] = true
+		sniffed[sc.PortB] = true
+	}
 	for _, pc := range config.SerialCfg.Ports {
 		p, err := serial.NewPort(pc)
 		if err != nil {
 			return fmt.Errorf("unsupported port %s: %w", pc.Name, err)
 		}
-		if err := p.Open(); err != nil {
-			return fmt.Errorf("open port %s: %w", pc.Name, err)
+		sup := serial.NewSupervisor(p,
+			time.Duration(pc.ReconnectMinMs)*time.Millisecond,
+			time.Duration(pc.ReconnectMaxMs)*time.Millisecond)
+		// 每个端口只运行一个调制解调器线监视，MQTT 上报与 RFC 2217 会话共享
+		var modem *serial.ModemHub
+		if _, ok := p.(serial.ModemController); ok {
+			modem = serial.NewModemHub()
+		}
+		sup.OnState(portStateHandler(mqttClient, p, pc, modem))
+		if err := sup.Start(); err != nil {
+			if !serial.IsAbsent(err) {
+				return fmt.Errorf("open port %s: %w", pc.Name, err)
+			}
+			// 适配器尚未插入或被其他进程占用：不阻止服务启动，设备可用后由热插拔/重试打开
+			sup.Await(err)
+		}
+		allPorts[pc.Name] = sup
+		if sniffed[pc.Name] {
+			taps[pc.Name] = sup
+			continue
+		}
+		portMap[pc.Name] = sup
+
+		// 可选的 TCP 共享，未配置时为 nil
+		share := serial.NewShareServer(sup, pc, modem)
+		writers[pc.Name] = &portWriter{sup: sup, share: share}
+		share.SetWriter(writers[pc.Name].direct())
+		share.OnWriter(shareWriterHandler(mqttClient, pc.Name))
+		if err := share.Start(); err != nil {
+			return fmt.Errorf("share port %s: %w", pc.Name, err)
+		}
+		shares[pc.Name] = share
+
+		// 可选的 pty 扇出，写入经与 MQTT 命令相同的路径
+		fanout := serial.NewFanout(pc)
+		if err := fanout.Start(writers[pc.Name]); err != nil {
+			return fmt.Errorf("fanout port %s: %w", pc.Name, err)
+		}
+		if paths := fanout.Paths(); len(paths) > 0 {
+			publishFanout(mqttClient, pc.Name, paths)
 		}
-		portMap[pc.Name] = p
+		fanouts[pc.Name] = fanout
 	}
-	// 3. 构建 port -> 协议ID 列表映射
+	startHotplug(allPorts)
+	// 3. 构建 port -> 协议ID 列表映射，解析出的帧同时分发给本地订阅者
 	portProtoss := make(map[string][]string, len(config.SerialCfg.Bindings))
 	for _, b := range config.SerialCfg.Bindings {
 		portProtoss[b.PortName] = append(portProtoss[b.PortName], b.ProtocolID)
 	}
+	hub := newFrameHub()
 	// 4. 单协程读循环：每个端口只起一个 goroutine，但支持多协议解析
 	for portName, port := range portMap {
 		protoIDs := portProtoss[portName]
@@ -62,20 +123,56 @@ func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
 				topics = append(topics, "")
 			}
 		}
+		// 可选的线路参数自动检测，按本端口绑定的解析器评分
+		pc, _ := config.GetPort(portName)
+		baud := serial.NewAutoBaud(port, pc, parsers)
+		baud.OnResult(autoBaudHandler(mqttClient, portName))
+		baud.SetWriter(writers[portName].direct())
+		writers[portName].baud = baud
+		// 读缓存时间线：记录每个读取块的到达时刻，并统计帧内字节间隔
+		tl := serial.NewRxTimeline(port.Port(), pc)
+		startGapReport(mqttClient, portName, tl.Gaps(), pc.StatsIntervalMs, port.Done())
 		// 启动单一解析循环
-		go func(p serial.Port, parsers []serial.FrameParser, topics []string, portName string) {
+		go func(p *serial.Supervisor, share *serial.ShareServer, fanout *serial.Fanout, baud *serial.AutoBaud, tl *serial.RxTimeline, parsers []serial.FrameParser, protoIDs, topics []string, portName string) {
 			var buf []byte
 			tmp := make([]byte, 256)
 			for {
-				// 读串口数据
+				// 读串口数据，返回后立即取时间（含单调时钟读数）
 				n, err := p.Read(tmp)
-				if err != nil {
+				at := time.Now()
+				if errors.Is(err, serial.ErrSupervisorClosed) {
+					return
+				}
+				brk := errors.Is(err, serial.ErrBreak)
+				if err != nil && !brk {
+					// 读取由轮询器驱动，空闲时不会返回；这里只有意外的非致命错误，稍后重试
 					time.Sleep(100 * time.Millisecond)
 					continue
 				}
+				if baud.Feed(tmp[:n]) {
+					// 检测期间的数据只用于评分
+					buf = nil
+					tl.Reset()
+					continue
+				}
 				s := string(tmp[:n])
 				fmt.Printf("⮈ [%s] Read %d bytes as string: %q\n", portName, n, s)
+				share.Broadcast(tmp[:n])
+				fanout.Broadcast(tmp[:n])
+				if brk {
+					share.NotifyBreak()
+				}
+				if share.PauseParser() {
+					// 共享写入客户端独占期间暂停解析，丢弃未成帧的数据
+					buf = nil
+					tl.Reset()
+					if brk {
+						publishBreak(mqttClient, portName)
+					}
+					continue
+				}
 				buf = append(buf, tmp[:n]...)
+				tl.Add(n, at)
 
 				// 多协议匹配解析
 				for {
@@ -84,15 +181,21 @@ func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
 						frame, rest, err := parse(buf)
 						if err != nil {
 							buf = nil
+							tl.Reset()
 							matched = false
 							break
 						}
 						if frame != nil {
+							// 由消费的字节区间求出帧首/尾字节的到达时刻
+							used := len(buf) - len(rest)
+							timing := tl.Span(serial.FrameOffset(buf[:used], frame), used)
+							tl.Consume(used)
+							hub.publish(frameEvent{Port: portName, Protocol: protoIDs[i], Frame: frame, Timing: timing})
 							topic := topics[i]
-							fmt.Printf("→ PublishSerialFrame params: topic=%s, port=%s, frame(%d)=% X\n",
-								topic, portName, len(frame), frame)
+							fmt.Printf("→ PublishSerialFrame params: topic=%s, port=%s, frame(%d)=% X, maxGap=%v\n",
+								topic, portName, len(frame), frame, timing.MaxGap)
 							if topic != "" {
-								if err := mqttclient.PublishSerialFrame(mqttClient, topic, portName, frame); err != nil {
+								if err := mqttclient.PublishSerialFrame(mqttClient, topic, portName, frame, timing); err != nil {
 									fmt.Printf("❌ publish failed: %v\n", err)
 								}
 							}
@@ -105,8 +208,17 @@ func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
 						break
 					}
 				}
+				// BREAK 之前的字节已照常解析，随后上报 BREAK 并丢弃未成帧的残余数据
+				if brk {
+					publishBreak(mqttClient, portName)
+					buf = nil
+					tl.Reset()
+				}
 			}
-		}(port, parsers, topics, portName)
+		}(port, shares[portName], fanouts[portName], baud, tl, parsers, protoIDs, topics, portName)
+		if baud != nil {
+			baud.Start()
+		}
 	}
 	// 5. 订阅所有协议的 requestTopic，把收到的 JSON 解包后写到对应串口
 	for _, pr := range config.SerialCfg.Protocols {
@@ -135,7 +247,7 @@ func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
 			// 写串口
 			portName := sp.Port
 			dataBytes := []byte(sp.Data)
-			if p, ok := portMap[portName]; ok {
+			if p, ok := writers[portName]; ok {
 				fmt.Printf("⇦ 写入串口: %s, 数据=% X\n", portName, dataBytes)
 				if _, err := p.Write(dataBytes); err != nil {
 					fmt.Printf("写入串口 %s 失败: %v\n", portName, err)
@@ -151,6 +263,34 @@ func InitializeSerialProxy(configPath string, mqttClient mqtt.Client) error {
 			fmt.Printf("✅ Successfully subscribed to topic=%s\n", topic)
 		}
 	}
+	// 6. 端口控制命令
+	subscribeControl(mqttClient, portMap, writers)
+
+	// 7. 本地 Unix 套接字接口
+	if api := config.SerialCfg.LocalAPI; api != nil {
+		protos := make(map[string][]string, len(portMap))
+		for name := range portMap {
+			protos[name] = portProtoss[name]
+			if len(protos[name]) == 0 {
+				protos[name] = []string{config.SerialCfg.DefaultProtocol}
+			}
+		}
+		if err := startLocalAPI(*api, portMap, writers, protos, hub); err != nil {
+			return fmt.Errorf("local API: %w", err)
+		}
+	}
+
+	// 8. 被动监听
+	if err := startSniffers(mqttClient, taps); err != nil {
+		return fmt.Errorf("sniffer: %w", err)
+	}
+
+	// 9. REST 线路参数接口
+	if sdk != nil {
+		if err := addLineRoutes(sdk, mqttClient, writers); err != nil {
+			return fmt.Errorf("REST API: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,309 @@
+package driver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// 本地接口协议：每条消息为 4 字节大端长度 + JSON。
+//
+// 请求 apiRequest.Op：
+//   - list：列出端口及其绑定的协议
+//   - subscribe / unsubscribe：按协议（可选限定端口）订阅解析出的帧，之后以 event=frame 推送
+//   - write：把 data 写入端口
+//   - transact：写入 data 并等待该端口的下一帧（可限定协议），超时 timeoutMs（默认 1000）
+//
+// 每个请求都以 event=reply 应答，id 原样带回。写入与 MQTT 命令共用端口的写入路径；
+// transact 在收到应答或超时前独占端口，其他 transact、write、MQTT 命令、pty 扇出和共享写入客户端都排队等待，
+// 保证应答帧对应各自的请求。
+const (
+	apiMaxMessage     = 1 << 20
+	apiDefaultTimeout = time.Second
+)
+
+// apiRequest 是本地接口的请求
+type apiRequest struct {
+	ID        string `json:"id,omitempty"`
+	Op        string `json:"op"`
+	Port      string `json:"port,omitempty"`
+	Protocol  string `json:"protocol,omitempty"`
+	Data      []byte `json:"data,omitempty"` // base64
+	TimeoutMs int    `json:"timeoutMs,omitempty"`
+}
+
+// apiMessage 是本地接口的应答或推送
+type apiMessage struct {
+	ID       string    `json:"id,omitempty"`
+	Event    string    `json:"event"` // reply/frame
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+	Ports    []apiPort `json:"ports,omitempty"`
+	Port     string    `json:"port,omitempty"`
+	Protocol string    `json:"protocol,omitempty"`
+	Data     []byte    `json:"data,omitempty"`      // base64
+	Time     int64     `json:"timestamp,omitempty"` // 帧首字节到达时刻，Unix 纳秒
+}
+
+// apiPort 是 list 返回的端口信息
+type apiPort struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Connected bool     `json:"connected"`
+	Protocols []string `json:"protocols"`
+}
+
+// apiSub 是一个帧订阅条件
+type apiSub struct {
+	port     string
+	protocol string
+}
+
+// localAPI 是本地 Unix 套接字接口
+type localAPI struct {
+	cfg     config.LocalAPIConfig
+	ports   map[string]*serial.Supervisor
+	writers map[string]*portWriter
+	protos  map[string][]string
+	hub     *frameHub
+}
+
+// startLocalAPI 在配置的路径上监听 Unix 套接字
+func startLocalAPI(cfg config.LocalAPIConfig, ports map[string]*serial.Supervisor, writers map[string]*portWriter,
+	protos map[string][]string, hub *frameHub) error {
+	// 清理上次运行遗留的套接字文件
+	if fi, err := os.Lstat(cfg.Socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(cfg.Socket)
+	}
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: cfg.Socket, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", cfg.Socket, err)
+	}
+	// 访问控制由对端凭据完成，套接字文件本身对所有用户可连
+	if err := os.Chmod(cfg.Socket, 0o666); err != nil {
+		ln.Close()
+		return fmt.Errorf("chmod %s: %w", cfg.Socket, err)
+	}
+	api := &localAPI{cfg: cfg, ports: ports, writers: writers, protos: protos, hub: hub}
+	fmt.Printf("🔌 local API listening on %s\n", cfg.Socket)
+	go api.accept(ln)
+	return nil
+}
+
+// accept 接受连接并校验对端凭据
+func (a *localAPI) accept(ln *net.UnixListener) {
+	for {
+		conn, err := ln.AcceptUnix()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("⚠️ local API accept failed: %v\n", err)
+			}
+			return
+		}
+		uid, gid, pid, err := peerCred(conn)
+		if err != nil {
+			fmt.Printf("⛔ local API: read peer credentials failed: %v\n", err)
+			conn.Close()
+			continue
+		}
+		if !slices.Contains(a.cfg.AllowUIDs, uid) && !slices.Contains(a.cfg.AllowGIDs, gid) {
+			fmt.Printf("⛔ local API: rejected pid=%d uid=%d gid=%d\n", pid, uid, gid)
+			conn.Close()
+			continue
+		}
+		fmt.Printf("🔌 local API: client pid=%d uid=%d connected\n", pid, uid)
+		go a.serve(conn)
+	}
+}
+
+// apiConn 是一个已连接的客户端
+type apiConn struct {
+	conn net.Conn
+	wmu  sync.Mutex
+
+	mu   sync.Mutex
+	subs map[apiSub]struct{}
+}
+
+// send 写出一条消息
+func (c *apiConn) send(m apiMessage) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(body)))
+	if _, err := c.conn.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err = c.conn.Write(body)
+	return err
+}
+
+// readRequest 读取一条请求
+func readRequest(r io.Reader) (apiRequest, error) {
+	var req apiRequest
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return req, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n > apiMaxMessage {
+		return req, fmt.Errorf("message too large: %d bytes", n)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return req, fmt.Errorf("decode request: %w", err)
+	}
+	return req, nil
+}
+
+// serve 处理一个客户端：请求并发执行（transact 不阻塞其他请求），订阅的帧由独立协程推送
+func (a *localAPI) serve(conn *net.UnixConn) {
+	c := &apiConn{conn: conn, subs: make(map[apiSub]struct{})}
+	frames, cancel := a.hub.subscribe()
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		cancel()
+		conn.Close()
+	}()
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case ev := <-frames:
+				if c.subscribed(ev) {
+					c.send(apiMessage{Event: "frame", OK: true, Port: ev.Port, Protocol: ev.Protocol, Data: ev.Frame, Time: ev.Timing.First.UnixNano()})
+				}
+			}
+		}
+	}()
+	for {
+		req, err := readRequest(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("⚠️ local API: %v\n", err)
+			}
+			return
+		}
+		go func() {
+			reply := a.handle(c, req)
+			reply.ID = req.ID
+			reply.Event = "reply"
+			c.send(reply)
+		}()
+	}
+}
+
+// subscribed 判断帧是否满足客户端的任一订阅
+func (c *apiConn) subscribed(ev frameEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, all := c.subs[apiSub{protocol: ev.Protocol}]
+	_, exact := c.subs[apiSub{port: ev.Port, protocol: ev.Protocol}]
+	return all || exact
+}
+
+// handle 执行一条请求
+func (a *localAPI) handle(c *apiConn, req apiRequest) apiMessage {
+	fail := func(err error) apiMessage { return apiMessage{Error: err.Error()} }
+	switch req.Op {
+	case "list":
+		return apiMessage{OK: true, Ports: a.list()}
+	case "subscribe", "unsubscribe":
+		if req.Protocol == "" {
+			return fail(fmt.Errorf("protocol is required"))
+		}
+		if req.Port != "" && a.ports[req.Port] == nil {
+			return fail(fmt.Errorf("unknown port %s", req.Port))
+		}
+		c.mu.Lock()
+		if req.Op == "subscribe" {
+			c.subs[apiSub{port: req.Port, protocol: req.Protocol}] = struct{}{}
+		} else {
+			delete(c.subs, apiSub{port: req.Port, protocol: req.Protocol})
+		}
+		c.mu.Unlock()
+		return apiMessage{OK: true}
+	case "write":
+		w, ok := a.writers[req.Port]
+		if !ok {
+			return fail(fmt.Errorf("unknown port %s", req.Port))
+		}
+		if _, err := w.Write(req.Data); err != nil {
+			return fail(err)
+		}
+		return apiMessage{OK: true}
+	case "transact":
+		frame, err := a.transact(req)
+		if err != nil {
+			return fail(err)
+		}
+		return apiMessage{OK: true, Port: frame.Port, Protocol: frame.Protocol, Data: frame.Frame, Time: frame.Timing.First.UnixNano()}
+	default:
+		return fail(fmt.Errorf("unknown op %q", req.Op))
+	}
+}
+
+// list 返回全部端口
+func (a *localAPI) list() []apiPort {
+	out := make([]apiPort, 0, len(config.SerialCfg.Ports))
+	for _, pc := range config.SerialCfg.Ports {
+		sup, ok := a.ports[pc.Name]
+		if !ok {
+			continue
+		}
+		out = append(out, apiPort{Name: pc.Name, Type: pc.Type, Connected: sup.Connected(), Protocols: a.protos[pc.Name]})
+	}
+	return out
+}
+
+// transact 独占端口写入请求并等待下一帧应答，期间其他写入排队等待
+func (a *localAPI) transact(req apiRequest) (frameEvent, error) {
+	w, ok := a.writers[req.Port]
+	if !ok {
+		return frameEvent{}, fmt.Errorf("unknown port %s", req.Port)
+	}
+	timeout := apiDefaultTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 先订阅再写入，避免错过很快返回的应答
+	frames, cancel := a.hub.subscribe()
+	defer cancel()
+	if _, err := w.writeLocked(req.Data); err != nil {
+		return frameEvent{}, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case ev := <-frames:
+			if ev.Port == req.Port && (req.Protocol == "" || ev.Protocol == req.Protocol) {
+				return ev, nil
+			}
+		case <-timer.C:
+			return frameEvent{}, fmt.Errorf("no response within %v", timeout)
+		}
+	}
+}
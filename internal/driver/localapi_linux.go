@@ -0,0 +1,26 @@
+package driver
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCred 通过 SO_PEERCRED 读取对端进程的 uid/gid/pid
+func peerCred(conn *net.UnixConn) (uid, gid uint32, pid int32, err error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := rc.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return 0, 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, 0, credErr
+	}
+	return cred.Uid, cred.Gid, cred.Pid, nil
+}
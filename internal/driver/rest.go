@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/device-sdk-go/v4/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v4/common"
+	"github.com/labstack/echo/v4"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// lineRoute 是查询（GET）和修改（PUT）端口线路参数的 REST 路径，挂在设备服务自身的 HTTP 服务上
+const lineRoute = common.ApiBase + "/serial/:port/line"
+
+// lineResponse 是线路参数接口的应答
+type lineResponse struct {
+	Port     string               `json:"port"`
+	Settings *serial.LineSettings `json:"settings,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// addLineRoutes 注册线路参数接口：PUT 的请求体与控制主题 reconfigure 的 settings 相同
+func addLineRoutes(sdk interfaces.DeviceServiceSDK, client mqtt.Client, writers map[string]*portWriter) error {
+	handler := func(c echo.Context) error {
+		name := c.Param("port")
+		w, ok := writers[name]
+		if !ok {
+			return c.JSON(http.StatusNotFound, lineResponse{Port: name, Error: fmt.Sprintf("unknown port %s", name)})
+		}
+		var patch []byte
+		if c.Request().Method == http.MethodPut {
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, lineResponse{Port: name, Error: err.Error()})
+			}
+			if len(body) == 0 {
+				return c.JSON(http.StatusBadRequest, lineResponse{Port: name, Error: "empty line settings"})
+			}
+			patch = body
+			fmt.Printf("▶ REST reconfigure: port=%s settings=%s\n", name, body)
+		}
+		ls, err := reconfigurePort(client, w, patch)
+		if err != nil {
+			fmt.Printf("❌ reconfigure %s failed: %v\n", name, err)
+			return c.JSON(lineStatus(err), lineResponse{Port: name, Error: err.Error()})
+		}
+		return c.JSON(http.StatusOK, lineResponse{Port: name, Settings: &ls})
+	}
+	if err := sdk.AddCustomRoute(lineRoute, interfaces.Authenticated, handler, http.MethodGet, http.MethodPut); err != nil {
+		return fmt.Errorf("add route %s: %w", lineRoute, err)
+	}
+	fmt.Printf("🌐 REST line settings at %s\n", lineRoute)
+	return nil
+}
+
+// lineStatus 把修改失败的原因映射为 HTTP 状态码
+func lineStatus(err error) int {
+	switch {
+	case errors.Is(err, serial.ErrPortDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, serial.ErrDetecting):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/linjuya-lu/device_uart_go/internal/mqttclient"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// sniffQueue 是两个方向读协程与合并协程之间的缓冲块数
+const sniffQueue = 256
+
+// sniffChunk 是某一方向一次读取到的数据
+type sniffChunk struct {
+	dir  int // 0 为方向 A，1 为方向 B
+	at   time.Time
+	data []byte
+	brk  bool
+}
+
+// sniffer 把两个端口作为同一链路的两个方向被动监听：
+// 按到达顺序合并、分方向解析，结果发布到 MQTT 并写入抓包文件；从不写任一端口
+type sniffer struct {
+	cfg      config.Sniffer
+	client   mqtt.Client
+	ports    [2]*serial.Supervisor
+	names    [2]string
+	labels   [2]string
+	parsers  []serial.FrameParser
+	protoIDs []string
+	timeline [2]*serial.RxTimeline // 各方向解析缓存的时间线，帧以首字节时刻上报
+	capture  *os.File              // 未配置抓包文件时为 nil
+
+	mu     sync.Mutex // 取时间戳与入队放在同一临界区内，保证通道顺序即到达顺序
+	chunks chan sniffChunk
+}
+
+// startSniffers 为每个监听配置启动两个方向的读协程和一个合并协程
+func startSniffers(client mqtt.Client, taps map[string]*serial.Supervisor) error {
+	for _, sc := range config.SerialCfg.Sniffers {
+		protoIDs := sc.Protocols
+		if len(protoIDs) == 0 {
+			protoIDs = []string{config.SerialCfg.DefaultProtocol}
+		}
+		parsers := make([]serial.FrameParser, 0, len(protoIDs))
+		for _, pid := range protoIDs {
+			fp, ok := serial.Parsers[pid]
+			if !ok {
+				return fmt.Errorf("no parser for protocol %s on sniffer %s", pid, sc.Name)
+			}
+			parsers = append(parsers, fp)
+		}
+		s := &sniffer{
+			cfg:      sc,
+			client:   client,
+			ports:    [2]*serial.Supervisor{taps[sc.PortA], taps[sc.PortB]},
+			names:    [2]string{sc.PortA, sc.PortB},
+			labels:   [2]string{sc.LabelA, sc.LabelB},
+			parsers:  parsers,
+			protoIDs: protoIDs,
+			chunks:   make(chan sniffChunk, sniffQueue),
+		}
+		for dir, name := range s.names {
+			pc, _ := config.GetPort(name)
+			s.timeline[dir] = serial.NewRxTimeline(s.ports[dir].Port(), pc)
+		}
+		if sc.CaptureFile != "" {
+			f, err := os.OpenFile(sc.CaptureFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return fmt.Errorf("sniffer %s capture file: %w", sc.Name, err)
+			}
+			s.capture = f
+		}
+		fmt.Printf("👂 sniffer %s: %s=%s %s=%s protocols=%v capture=%q\n",
+			sc.Name, sc.LabelA, sc.PortA, sc.LabelB, sc.PortB, protoIDs, sc.CaptureFile)
+		go s.read(0)
+		go s.read(1)
+		go s.run()
+	}
+	return nil
+}
+
+// read 读取一个方向的数据，读到即打时间戳入队
+func (s *sniffer) read(dir int) {
+	p := s.ports[dir]
+	tmp := make([]byte, 256)
+	for {
+		n, err := p.Read(tmp)
+		if errors.Is(err, serial.ErrSupervisorClosed) {
+			return
+		}
+		brk := errors.Is(err, serial.ErrBreak)
+		if err != nil && !brk {
+			// 读取由轮询器驱动，空闲时不会返回；这里只有意外的非致命错误，稍后重试
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		if n == 0 && !brk {
+			continue
+		}
+		data := make([]byte, n)
+		copy(data, tmp[:n])
+		s.mu.Lock()
+		s.chunks <- sniffChunk{dir: dir, at: time.Now(), data: data, brk: brk}
+		s.mu.Unlock()
+	}
+}
+
+// run 按到达顺序处理两个方向的数据块，各方向维护独立的解析缓存
+func (s *sniffer) run() {
+	var bufs [2][]byte
+	for c := range s.chunks {
+		label := s.labels[c.dir]
+		if len(c.data) > 0 {
+			fmt.Printf("👂 [%s] %s %d bytes: % X\n", s.cfg.Name, label, len(c.data), c.data)
+			s.record(c.at, label, "data", c.data)
+			s.timeline[c.dir].Add(len(c.data), c.at)
+			bufs[c.dir] = s.parse(c.dir, append(bufs[c.dir], c.data...))
+		}
+		if c.brk {
+			// 与普通读循环一致：BREAK 之后丢弃未成帧的残余数据
+			s.record(c.at, label, "break", nil)
+			s.publish(c.dir, c.at, mqttclient.SniffPayload{Event: "break"})
+			bufs[c.dir] = nil
+			s.timeline[c.dir].Reset()
+		}
+	}
+}
+
+// parse 从某一方向的缓存中提取所有完整帧，返回未成帧的剩余数据
+func (s *sniffer) parse(dir int, buf []byte) []byte {
+	tl := s.timeline[dir]
+	for {
+		matched := false
+		for i, parse := range s.parsers {
+			frame, rest, err := parse(buf)
+			if err != nil {
+				tl.Reset()
+				return nil
+			}
+			if frame != nil {
+				used := len(buf) - len(rest)
+				timing := tl.Span(serial.FrameOffset(buf[:used], frame), used)
+				tl.Consume(used)
+				s.record(timing.First, s.labels[dir], "frame "+s.protoIDs[i], frame)
+				s.publish(dir, timing.First, mqttclient.SniffPayload{
+					Event:    "frame",
+					Protocol: s.protoIDs[i],
+					Data:     strings.ToUpper(hex.EncodeToString(frame)),
+				})
+				buf = rest
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return buf
+		}
+	}
+}
+
+// publish 补全方向与时间戳后发布到监听主题
+func (s *sniffer) publish(dir int, at time.Time, p mqttclient.SniffPayload) {
+	p.Sniffer = s.cfg.Name
+	p.Direction = s.labels[dir]
+	p.Port = s.names[dir]
+	p.Timestamp = at.UnixNano()
+	if err := mqttclient.PublishSniff(s.client, config.SnifferTopic(s.cfg.Name), p); err != nil {
+		fmt.Printf("❌ publish sniff failed: %v\n", err)
+	}
+}
+
+// record 向抓包文件追加一行：<RFC3339Nano 时间> <方向> <类型> [十六进制数据]
+func (s *sniffer) record(at time.Time, label, kind string, data []byte) {
+	if s.capture == nil {
+		return
+	}
+	line := fmt.Sprintf("%s %s %s", at.UTC().Format(time.RFC3339Nano), label, kind)
+	if len(data) > 0 {
+		line += fmt.Sprintf(" % X", data)
+	}
+	if _, err := s.capture.WriteString(line + "\n"); err != nil {
+		fmt.Printf("❌ [%s] write capture failed: %v\n", s.cfg.Name, err)
+	}
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/linjuya-lu/device_uart_go/internal/mqttclient"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// startStatsPoll 按 statsIntervalMs 轮询端口的驱动计数，
+// 以增量形式发布 stats 事件，错误率超过阈值时另发 errorRate 告警
+func startStatsPoll(client mqtt.Client, p serial.Port, cfg config.Port, stop <-chan struct{}) {
+	cr, ok := p.(serial.CounterReader)
+	if !ok || cfg.StatsIntervalMs <= 0 {
+		return
+	}
+	interval := time.Duration(cfg.StatsIntervalMs) * time.Millisecond
+	name := p.Name()
+	topic := config.StatusTopic(name)
+	go func() {
+		prev, err := cr.Counters()
+		if err != nil {
+			fmt.Printf("⚠️ [%s] driver counters unavailable: %v\n", name, err)
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			cur, err := cr.Counters()
+			if err != nil {
+				fmt.Printf("⚠️ [%s] read driver counters failed: %v\n", name, err)
+				continue
+			}
+			delta := cur.Sub(prev)
+			prev = cur
+			data := map[string]interface{}{
+				"intervalMs": cfg.StatsIntervalMs,
+				"delta":      delta,
+				"total":      cur,
+			}
+			if err := mqttclient.PublishPortStatus(client, topic, name, "stats", data); err != nil {
+				fmt.Printf("❌ publish stats failed: %v\n", err)
+			}
+			checkErrorRate(client, name, cfg.ErrorRateThreshold, delta)
+		}
+	}()
+}
+
+// checkErrorRate 在区间错误率超过阈值时发布告警；区间内没有收到数据但有错误时同样告警
+func checkErrorRate(client mqtt.Client, name string, threshold float64, delta serial.Counters) {
+	errs := delta.Errors()
+	if threshold <= 0 || errs == 0 {
+		return
+	}
+	rate := 1.0
+	if delta.Rx > 0 {
+		rate = float64(errs) / float64(delta.Rx)
+	}
+	if rate < threshold {
+		return
+	}
+	fmt.Printf("⚠️ [%s] error rate %.4f exceeds %.4f: %+v\n", name, rate, threshold, delta)
+	data := map[string]interface{}{
+		"rate":      rate,
+		"threshold": threshold,
+		"delta":     delta,
+	}
+	if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "errorRate", data); err != nil {
+		fmt.Printf("❌ publish error rate warning failed: %v\n", err)
+	}
+}
+
+// startGapReport 按统计间隔上报帧内字节间隔（最小/最大/平均），区间内没有多块帧时不上报；
+// 统计跨越重连累积，直到 stop 关闭（停止监管）时退出
+func startGapReport(client mqtt.Client, name string, gaps *serial.GapStats, intervalMs int, stop <-chan struct{}) {
+	if intervalMs <= 0 {
+		return
+	}
+	topic := config.StatusTopic(name)
+	go func() {
+		ticker := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			snap := gaps.Snapshot()
+			if snap.Count == 0 {
+				continue
+			}
+			data := map[string]interface{}{"intervalMs": intervalMs, "gaps": snap}
+			if err := mqttclient.PublishPortStatus(client, topic, name, "gaps", data); err != nil {
+				fmt.Printf("❌ publish gap stats failed: %v\n", err)
+			}
+		}
+	}()
+}
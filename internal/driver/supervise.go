@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/linjuya-lu/device_uart_go/internal/mqttclient"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// portStateHandler 返回端口连接状态回调：
+// 每次连接/断开都发布 state 事件；连接期间运行调制解调器监视（变化经 modem 分发）与计数轮询，断开时停止
+func portStateHandler(client mqtt.Client, p serial.Port, cfg config.Port, modem *serial.ModemHub) func(bool, error) {
+	var (
+		mu   sync.Mutex
+		stop chan struct{}
+	)
+	return func(connected bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stop != nil {
+			close(stop)
+			stop = nil
+		}
+
+		data := map[string]interface{}{"connected": connected}
+		if err != nil {
+			data["error"] = err.Error()
+		}
+		if pp, ok := p.(serial.PeerPather); ok && connected {
+			// 虚拟端口上报对端应打开的路径
+			data["path"] = pp.PeerPath()
+		}
+		if perr := mqttclient.PublishPortStatus(client, config.StatusTopic(cfg.Name), cfg.Name, "state", data); perr != nil {
+			fmt.Printf("❌ publish state failed: %v\n", perr)
+		}
+
+		if connected {
+			stop = make(chan struct{})
+			startModemWatch(client, p, modem, stop)
+			startStatsPoll(client, p, cfg, stop)
+		}
+	}
+}
+
+// startHotplug 监听内核 tty uevent，设备插入时立即打开对应端口、拔出时关闭；
+// 无法订阅 netlink（如受限容器）时仅依靠监管器的退避重试
+func startHotplug(portMap map[string]*serial.Supervisor) {
+	src, err := serial.NewNetlinkSource()
+	if err != nil {
+		fmt.Printf("⚠️ hotplug disabled, falling back to periodic reopen: %v\n", err)
+		return
+	}
+	hp := serial.NewHotplug(src)
+	for _, pc := range config.SerialCfg.Ports {
+		if sup, ok := portMap[pc.Name]; ok {
+			hp.Add(pc, sup)
+		}
+	}
+	go func() {
+		if err := hp.Run(); err != nil {
+			fmt.Printf("⚠️ hotplug listener stopped: %v\n", err)
+		}
+	}()
+}
+
+// shareWriterHandler 返回共享写入客户端接入/断开回调，发布 share 事件
+func shareWriterHandler(client mqtt.Client, name string) func(string, bool) {
+	return func(addr string, attached bool) {
+		data := map[string]interface{}{"writer": addr, "attached": attached}
+		if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "share", data); err != nil {
+			fmt.Printf("❌ publish share status failed: %v\n", err)
+		}
+	}
+}
+
+// publishFanout 上报端口扇出的 pty 路径
+func publishFanout(client mqtt.Client, name string, paths []string) {
+	data := map[string]interface{}{"paths": paths}
+	if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "fanout", data); err != nil {
+		fmt.Printf("❌ publish fanout status failed: %v\n", err)
+	}
+}
+
+// autoBaudHandler 返回线路参数自动检测结束回调，发布 autoBaud 事件
+func autoBaudHandler(client mqtt.Client, name string) func(serial.AutoBaudResult) {
+	return func(res serial.AutoBaudResult) {
+		if err := mqttclient.PublishPortStatus(client, config.StatusTopic(name), name, "autoBaud", res); err != nil {
+			fmt.Printf("❌ publish autoBaud status failed: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package driver
+
+import (
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
+)
+
+// portWriter 是端口的统一写入路径（MQTT 命令、pty 扇出、本地接口等）：
+// 共享写入客户端独占且配置为 pause 或正在自动检测线路参数时拒绝写入，
+// 其余写入逐条串行，避免两路数据交错。
+// mu 是端口唯一的仲裁：每次写入、写入-等待应答事务（直到收到应答或超时）与线路参数修改都持有它，
+// 事务期间其他任何写入路径都不会插入数据
+type portWriter struct {
+	mu    sync.Mutex
+	sup   *serial.Supervisor
+	share *serial.ShareServer
+	baud  *serial.AutoBaud
+}
+
+// Write 实现 io.Writer
+func (w *portWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeLocked(p)
+}
+
+// writeLocked 检查共享与自动检测状态后写入，调用方需持有 w.mu
+func (w *portWriter) writeLocked(p []byte) (int, error) {
+	if w.share.PauseCommands() {
+		return 0, serial.ErrPortBusy
+	}
+	if w.baud.Active() {
+		return 0, serial.ErrDetecting
+	}
+	return w.sup.Write(p)
+}
+
+// direct 返回不做暂停与检测检查、只与其他访问互斥的端口访问路径，
+// 供共享写入客户端（含 RFC 2217 会话的线路参数修改）与自动检测使用：前者正是暂停的原因，后者在检测期间写入和切换参数
+func (w *portWriter) direct() serial.PortAccess {
+	return directWriter{w}
+}
+
+// directWriter 是 portWriter.direct 返回的写入路径
+type directWriter struct {
+	w *portWriter
+}
+
+// Write 实现 io.Writer
+func (d directWriter) Write(p []byte) (int, error) {
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
+	return d.w.sup.Write(p)
+}
+
+// UpdateLine 实现 serial.PortAccess
+func (d directWriter) UpdateLine(update func(cur serial.LineSettings) (serial.LineSettings, bool)) (serial.LineSettings, error) {
+	d.w.mu.Lock()
+	defer d.w.mu.Unlock()
+	return serial.ApplyLineUpdate(d.w.sup.Port(), update)
+}
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/linjuya-lu/device_uart_go/internal/serial"
 
 	"github.com/google/uuid"
 )
@@ -50,15 +51,18 @@ type EdgexMessage struct {
 // SerialPayload 是 payload 部分的结构
 type SerialPayload struct {
 	Port      string `json:"port"`
-	Timestamp int64  `json:"timestamp"` // Unix 纳秒
-	Data      string `json:"data"`      // 这里用 Base64 编码原始二进制
+	Timestamp int64  `json:"timestamp"`          // Unix 纳秒；上报的帧为首字节到达时刻
+	LastByte  int64  `json:"lastByte,omitempty"` // 上报的帧尾字节到达时刻，Unix 纳秒
+	MaxGapUs  int64  `json:"maxGapUs,omitempty"` // 上报的帧内最大字节间隔（微秒）
+	Data      string `json:"data"`               // 这里用 Base64 编码原始二进制
 }
 
 // PublishSerialFrame 组装并发布一条 EdgeX 格式的消息：
 //   - topic: 要发布的 MQTT 主题
 //   - port:  串口设备节点，如 "/dev/ttyUSB1"
 //   - frame: 串口读到的原始 []byte 数据
-func PublishSerialFrame(client mqtt.Client, topic, port string, frame []byte) error {
+//   - timing: 帧首/尾字节到达时刻，首字节时刻作为消息时间戳，保证跨端口的事件顺序
+func PublishSerialFrame(client mqtt.Client, topic, port string, frame []byte, timing serial.FrameTiming) error {
 	// 调用入口打印原始二进制
 	fmt.Printf("▶ PublishSerialFrame called: topic=%s, port=%s, raw frame=% X\n", topic, port, frame)
 
@@ -69,7 +73,9 @@ func PublishSerialFrame(client mqtt.Client, topic, port string, frame []byte) er
 	// 1. 内层 payload: Data 字段存放十六进制字符串
 	payload := SerialPayload{
 		Port:      port,
-		Timestamp: time.Now().UnixNano(),
+		Timestamp: timing.First.UnixNano(),
+		LastByte:  timing.Last.UnixNano(),
+		MaxGapUs:  timing.MaxGap.Microseconds(),
 		Data:      hexData,
 	}
 
@@ -104,3 +110,91 @@ func PublishSerialFrame(client mqtt.Client, topic, port string, frame []byte) er
 	}
 	return tok.Error()
 }
+
+// PortStatusPayload 是端口状态事件的 payload
+type PortStatusPayload struct {
+	Port      string      `json:"port"`
+	Timestamp int64       `json:"timestamp"` // Unix 纳秒
+	Event     string      `json:"event"`     // 事件类型，如 modem/break/control
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// ControlPayload 是端口控制命令的 payload
+type ControlPayload struct {
+	Port       string          `json:"port"`
+	Action     string          `json:"action"`               // setLine/pulseLine/getLines/sendBreak/detectLine/reconfigure
+	Line       string          `json:"line,omitempty"`       // dtr/rts
+	Value      bool            `json:"value,omitempty"`      // 目标电平（pulse 时为脉冲期间的电平）
+	DurationMs int             `json:"durationMs,omitempty"` // 脉冲或 BREAK 持续时间（毫秒）
+	Settings   json.RawMessage `json:"settings,omitempty"`   // reconfigure 要修改的线路参数，未给出的字段保持不变
+}
+
+// UnmarshalPayload 解开 EdgeX 外层消息，把 payload 反序列化到 v
+func UnmarshalPayload(body []byte, v interface{}) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return fmt.Errorf("解析外层失败: %w", err)
+	}
+	p, ok := raw["payload"]
+	if !ok {
+		return fmt.Errorf("消息缺少 payload")
+	}
+	if err := json.Unmarshal(p, v); err != nil {
+		return fmt.Errorf("解析 payload 失败: %w", err)
+	}
+	return nil
+}
+
+// PublishPortStatus 以 EdgeX 消息格式发布一条端口状态事件
+func PublishPortStatus(client mqtt.Client, topic, port, event string, data interface{}) error {
+	msg := EdgexMessage{
+		ApiVersion:    "v3",
+		CorrelationID: uuid.NewString(),
+		RequestID:     uuid.NewString(),
+		Payload: PortStatusPayload{
+			Port:      port,
+			Timestamp: time.Now().UnixNano(),
+			Event:     event,
+			Data:      data,
+		},
+		ContentType: "application/json",
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("⮉ Publishing status topic=%s, message=%s\n", topic, string(body))
+	tok := client.Publish(topic, 0, false, body)
+	tok.Wait()
+	return tok.Error()
+}
+
+// SniffPayload 是被动监听结果的 payload
+type SniffPayload struct {
+	Sniffer   string `json:"sniffer"`
+	Direction string `json:"direction"` // 方向标签，如 master/slave
+	Port      string `json:"port"`
+	Timestamp int64  `json:"timestamp"` // 到达时刻，Unix 纳秒；帧为首字节时刻
+	Event     string `json:"event"`     // frame/break
+	Protocol  string `json:"protocol,omitempty"`
+	Data      string `json:"data,omitempty"` // 帧的十六进制字符串
+}
+
+// PublishSniff 以 EdgeX 消息格式发布一条被动监听结果
+func PublishSniff(client mqtt.Client, topic string, p SniffPayload) error {
+	msg := EdgexMessage{
+		ApiVersion:    "v3",
+		CorrelationID: uuid.NewString(),
+		RequestID:     uuid.NewString(),
+		Payload:       p,
+		ContentType:   "application/json",
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("⮉ Publishing sniff topic=%s, message=%s\n", topic, string(body))
+	tok := client.Publish(topic, 0, false, body)
+	tok.Wait()
+	return tok.Error()
+}
@@ -0,0 +1,307 @@
+package serial
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// ErrDetecting 表示端口正在自动检测线路参数，期间不接受写入
+var ErrDetecting = errors.New("line settings detection in progress")
+
+// autoBaudSettle 是切换线路参数后丢弃输入的时间，避免把旧参数下收到的字节计入新组合
+const autoBaudSettle = 50 * time.Millisecond
+
+// BaudScore 是一个组合的检测得分
+type BaudScore struct {
+	Settings LineSettings `json:"settings"`
+	Frames   int          `json:"frames"` // 绑定协议成功解析的帧数
+	Errors   int          `json:"errors"` // 解析器报错次数
+}
+
+// AutoBaudResult 是一次自动检测的结果
+type AutoBaudResult struct {
+	Detected bool         `json:"detected"`
+	Settings LineSettings `json:"settings"` // 检测后端口使用的线路参数，未检测到时为原参数
+	Scores   []BaudScore  `json:"scores"`
+}
+
+// AutoBaud 依次把端口切换到各候选组合，统计读循环送来的数据能解析出多少帧，
+// 最后锁定得分最高的组合。检测期间读循环把数据交给 Feed，不做常规处理。
+type AutoBaud struct {
+	sup      *Supervisor
+	base     config.Port
+	cfg      config.AutoBaudConfig
+	parsers  []FrameParser
+	probe    []byte
+	out      PortAccess // 探测帧写入与切换组合的访问路径，默认直接访问监管器
+	onResult func(AutoBaudResult)
+
+	mu          sync.Mutex
+	active      bool
+	cur         *BaudScore // 当前组合的得分，切换间隙为 nil
+	settleUntil time.Time
+	buf         []byte
+}
+
+// NewAutoBaud 为配置了 autoBaud 的端口创建检测器，未配置时返回 nil（所有方法均可安全调用）
+func NewAutoBaud(sup *Supervisor, pc config.Port, parsers []FrameParser) *AutoBaud {
+	if pc.AutoBaud == nil {
+		return nil
+	}
+	// probe 已在加载配置时校验
+	probe, _ := hex.DecodeString(pc.AutoBaud.Probe)
+	return &AutoBaud{sup: sup, base: pc, cfg: *pc.AutoBaud, parsers: parsers, probe: probe, out: supervisorAccess{sup}}
+}
+
+// SetWriter 设置写入探测帧、切换候选组合的路径，使其与进行中的事务和其他写入互斥，需在 Start 之前调用
+func (a *AutoBaud) SetWriter(w PortAccess) {
+	if a == nil {
+		return
+	}
+	a.out = w
+}
+
+// OnResult 设置检测结束时的回调
+func (a *AutoBaud) OnResult(fn func(AutoBaudResult)) {
+	if a == nil {
+		return
+	}
+	a.onResult = fn
+}
+
+// Start 在后台开始一次检测；端口断开时等到重新连接后再开始
+func (a *AutoBaud) Start() error {
+	if a == nil {
+		return fmt.Errorf("autoBaud is not configured")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active {
+		return ErrDetecting
+	}
+	a.active = true
+	go a.run()
+	return nil
+}
+
+// Active 判断是否正在检测
+func (a *AutoBaud) Active() bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.active
+}
+
+// Feed 接收读循环读到的数据；检测期间返回 true，读循环应跳过常规处理
+func (a *AutoBaud) Feed(p []byte) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.active {
+		return false
+	}
+	if a.cur == nil || time.Now().Before(a.settleUntil) {
+		return true
+	}
+	a.buf = append(a.buf, p...)
+	for {
+		matched := false
+		for _, parse := range a.parsers {
+			frame, rest, err := parse(a.buf)
+			if err != nil {
+				a.cur.Errors++
+				a.buf = nil
+				break
+			}
+			if frame != nil {
+				a.cur.Frames++
+				a.buf = rest
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return true
+		}
+	}
+}
+
+// run 执行一次完整检测：上次保存的结果排在最前，仍然有效时直接采用
+func (a *AutoBaud) run() {
+	name := a.sup.Name()
+	res := AutoBaudResult{}
+	defer func() {
+		a.mu.Lock()
+		a.active = false
+		a.cur = nil
+		a.buf = nil
+		a.mu.Unlock()
+		if a.onResult != nil {
+			a.onResult(res)
+		}
+	}()
+
+	if err := a.sup.waitConnected(); err != nil {
+		return
+	}
+	// 读取原参数同样等待进行中的事务结束
+	orig, err := a.out.UpdateLine(func(cur LineSettings) (LineSettings, bool) { return cur, false })
+	if err != nil {
+		fmt.Printf("⚠️ [%s] %v, autoBaud skipped\n", name, err)
+		return
+	}
+	res.Settings = orig
+	cands, persisted := a.candidates()
+	fmt.Printf("🔍 [%s] detecting line settings over %d candidates\n", name, len(cands))
+
+	best := -1
+	for i, ls := range cands {
+		score, err := a.try(ls)
+		if err != nil {
+			fmt.Printf("⚠️ [%s] candidate %d %d/%s/%v skipped: %v\n", name,
+				ls.Baudrate, ls.DataBits, ls.Parity, ls.StopBits, err)
+			continue
+		}
+		fmt.Printf("🔍 [%s] %d %d/%s/%v → %d frames, %d errors\n", name,
+			ls.Baudrate, ls.DataBits, ls.Parity, ls.StopBits, score.Frames, score.Errors)
+		res.Scores = append(res.Scores, score)
+		if best < 0 || better(score, res.Scores[best]) {
+			best = len(res.Scores) - 1
+		}
+		if i == 0 && persisted && score.Frames >= a.cfg.MinFrames {
+			// 上次检测的结果仍然有效，不再尝试其他组合
+			break
+		}
+	}
+
+	if best < 0 || res.Scores[best].Frames < a.cfg.MinFrames {
+		fmt.Printf("⚠️ [%s] no candidate decoded %d frames, keeping %d %d/%s/%v\n", name,
+			a.cfg.MinFrames, orig.Baudrate, orig.DataBits, orig.Parity, orig.StopBits)
+		if err := a.set(orig); err != nil {
+			fmt.Printf("❌ [%s] restore line settings failed: %v\n", name, err)
+		}
+		return
+	}
+	ls := res.Scores[best].Settings
+	if err := a.set(ls); err != nil {
+		fmt.Printf("❌ [%s] apply detected line settings failed: %v\n", name, err)
+		return
+	}
+	res.Detected = true
+	res.Settings = ls
+	fmt.Printf("✅ [%s] detected line settings %d %d/%s/%v\n", name, ls.Baudrate, ls.DataBits, ls.Parity, ls.StopBits)
+	a.save(ls)
+}
+
+// set 经端口访问路径切换线路参数，等待进行中的事务结束后才切换
+func (a *AutoBaud) set(ls LineSettings) error {
+	_, err := a.out.UpdateLine(func(LineSettings) (LineSettings, bool) { return ls, true })
+	return err
+}
+
+// try 切换到一个组合，可选发送探测帧，统计监听期间解析出的帧数
+func (a *AutoBaud) try(ls LineSettings) (BaudScore, error) {
+	if !a.sup.Connected() {
+		return BaudScore{}, ErrPortDown
+	}
+	if err := a.set(ls); err != nil {
+		return BaudScore{}, err
+	}
+	score := &BaudScore{Settings: ls}
+	a.mu.Lock()
+	a.cur = score
+	a.buf = nil
+	a.settleUntil = time.Now().Add(autoBaudSettle)
+	a.mu.Unlock()
+
+	time.Sleep(autoBaudSettle)
+	if len(a.probe) > 0 {
+		if _, err := a.out.Write(a.probe); err != nil {
+			fmt.Printf("⚠️ [%s] autoBaud probe failed: %v\n", a.sup.Name(), err)
+		}
+	}
+	time.Sleep(time.Duration(a.cfg.DwellMs) * time.Millisecond)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cur = nil
+	return *score, nil
+}
+
+// better 比较两个得分：帧数多者优先，帧数相同时错误少者优先
+func better(s, than BaudScore) bool {
+	if s.Frames != than.Frames {
+		return s.Frames > than.Frames
+	}
+	return s.Errors < than.Errors
+}
+
+// candidates 返回去重后的候选线路参数，存在已保存的结果时放在最前
+func (a *AutoBaud) candidates() ([]LineSettings, bool) {
+	var out []LineSettings
+	seen := make(map[LineSettings]bool)
+	add := func(ls LineSettings) {
+		if !seen[ls] {
+			seen[ls] = true
+			out = append(out, ls)
+		}
+	}
+	persisted := false
+	if ls, ok := a.load(); ok {
+		add(ls)
+		persisted = true
+	}
+	for _, c := range a.cfg.Candidates {
+		add(lineSettingsOf(a.base.WithCandidate(c)))
+	}
+	return out, persisted
+}
+
+// load 读取已保存的检测结果，文件不存在或不是本端口可用的参数时忽略
+func (a *AutoBaud) load() (LineSettings, bool) {
+	var ls LineSettings
+	if a.cfg.Persist == "" {
+		return ls, false
+	}
+	data, err := os.ReadFile(a.cfg.Persist)
+	if err != nil {
+		return ls, false
+	}
+	if err := json.Unmarshal(data, &ls); err != nil {
+		fmt.Printf("⚠️ [%s] ignore autoBaud persist file %s: %v\n", a.sup.Name(), a.cfg.Persist, err)
+		return ls, false
+	}
+	// 只保存检测出的波特率/数据位/校验/停止位，其余沿用配置
+	ls.FlowControl = a.base.FlowControl
+	ls.InterCharTimeoutMs, ls.MinRead = a.base.InterCharTimeoutMs, a.base.MinRead
+	if _, err := withLineSettings(a.base, ls); err != nil {
+		fmt.Printf("⚠️ [%s] ignore autoBaud persist file %s: %v\n", a.sup.Name(), a.cfg.Persist, err)
+		return ls, false
+	}
+	return ls, true
+}
+
+// save 保存检测结果
+func (a *AutoBaud) save(ls LineSettings) {
+	if a.cfg.Persist == "" {
+		return
+	}
+	data, err := json.Marshal(ls)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(a.cfg.Persist, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("❌ [%s] save autoBaud result to %s failed: %v\n", a.sup.Name(), a.cfg.Persist, err)
+	}
+}
@@ -0,0 +1,111 @@
+package serial
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBreak 由 Read 返回，表示在已返回的 n 个字节之后收到了一个 BREAK。
+// 读循环应先处理这 n 个字节，再把 BREAK 作为独立事件处理。
+var ErrBreak = errors.New("serial break received")
+
+// BreakSender 由可以发送 BREAK 的端口实现
+type BreakSender interface {
+	// SendBreak 发送持续 d 的 BREAK；d 为 0 时使用驱动默认时长（0.25~0.5 秒）
+	SendBreak(d time.Duration) error
+}
+
+// PARMRK 标记状态
+const (
+	markNone = iota // 普通数据
+	markFF          // 已收到 0xFF
+	markFF00        // 已收到 0xFF 0x00
+)
+
+// breakDecoder 解码开启 PARMRK 后的接收流：
+//   - 0xFF 0xFF       → 数据字节 0xFF
+//   - 0xFF 0x00 0x00  → BREAK
+//   - 0xFF 0x00 X     → 帧/校验错误的字节 X，丢弃并计数
+//
+// nil 表示未启用，直接放行。
+type breakDecoder struct {
+	state   int
+	raw     []byte // 已读取、尚未解码的原始字节
+	tmp     []byte
+	breaks  uint64
+	framing uint64
+}
+
+func newBreakDecoder(enabled bool) *breakDecoder {
+	if !enabled {
+		return nil
+	}
+	return &breakDecoder{}
+}
+
+// decode 把 raw 解码到 p，遇到 BREAK 时停止并返回 brk=true
+func (d *breakDecoder) decode(p []byte) (n int, brk bool) {
+	i := 0
+	for ; i < len(d.raw) && n < len(p); i++ {
+		b := d.raw[i]
+		switch d.state {
+		case markNone:
+			if b == 0xFF {
+				d.state = markFF
+				continue
+			}
+			p[n] = b
+			n++
+		case markFF:
+			d.state = markNone
+			switch b {
+			case 0xFF:
+				p[n] = 0xFF
+				n++
+			case 0x00:
+				d.state = markFF00
+			default:
+				// 不应出现的序列，按原样交付后一个字节
+				p[n] = b
+				n++
+			}
+		case markFF00:
+			d.state = markNone
+			if b == 0x00 {
+				d.breaks++
+				d.raw = d.raw[i+1:]
+				return n, true
+			}
+			d.framing++
+		}
+	}
+	d.raw = d.raw[i:]
+	return n, false
+}
+
+// read 调用 readFn 读取原始数据并解码，遇到 BREAK 返回 ErrBreak
+func (d *breakDecoder) read(p []byte, readFn func([]byte) (int, error)) (int, error) {
+	if d == nil {
+		return readFn(p)
+	}
+	for {
+		if len(d.raw) > 0 {
+			n, brk := d.decode(p)
+			if brk {
+				return n, ErrBreak
+			}
+			if n > 0 {
+				return n, nil
+			}
+		}
+		if cap(d.tmp) < len(p) {
+			d.tmp = make([]byte, len(p))
+		}
+		m, err := readFn(d.tmp[:len(p)])
+		d.raw = append(d.raw, d.tmp[:m]...)
+		if err != nil || m == 0 {
+			// 出错或超时：已读到的原始数据留待下次解码
+			return 0, err
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package serial
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendBreak 在 fd 上发送 BREAK：
+//   - d <= 0：TCSBRK，由驱动决定时长
+//   - d 为 100ms 的整数倍：TCSBRKP（以 0.1 秒为单位）
+//   - 其他时长：排空发送缓冲后 TIOCSBRK，计时 d，再 TIOCCBRK
+func sendBreak(fd uintptr, d time.Duration) error {
+	switch {
+	case d <= 0:
+		if err := unix.IoctlSetInt(int(fd), unix.TCSBRK, 0); err != nil {
+			return fmt.Errorf("TCSBRK: %w", err)
+		}
+	case d%(100*time.Millisecond) == 0:
+		if err := unix.IoctlSetInt(int(fd), unix.TCSBRKP, int(d/(100*time.Millisecond))); err != nil {
+			return fmt.Errorf("TCSBRKP: %w", err)
+		}
+	default:
+		if err := unix.IoctlSetInt(int(fd), unix.TCSBRK, 1); err != nil {
+			return fmt.Errorf("tcdrain: %w", err)
+		}
+		if err := unix.IoctlSetInt(int(fd), unix.TIOCSBRK, 0); err != nil {
+			return fmt.Errorf("TIOCSBRK: %w", err)
+		}
+		time.Sleep(d)
+		if err := unix.IoctlSetInt(int(fd), unix.TIOCCBRK, 0); err != nil {
+			return fmt.Errorf("TIOCCBRK: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package serial
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// echoCanceller 用于两线制 RS-485 等发送时接收器仍开启的场景：
+// 记录刚发送的字节，并在窗口期内从接收流中剥离与之匹配的前缀。
+// 回显与发送内容不一致时计为一次总线冲突。
+// nil 表示未启用，所有方法直接放行。
+type echoCanceller struct {
+	mu         sync.Mutex
+	charTime   time.Duration // 单字符发送时间
+	window     time.Duration // 发送完成后等待回显的时间
+	pending    []byte        // 已发送、尚未收到回显的字节
+	deadline   time.Time     // 超过该时间仍未回显则放弃
+	collisions uint64
+}
+
+// newEchoCanceller 按端口配置创建回显抑制器，未启用时返回 nil
+func newEchoCanceller(cfg config.Port) *echoCanceller {
+	if !cfg.EchoCancel {
+		return nil
+	}
+	return &echoCanceller{
+		charTime: charTime(cfg),
+		window:   time.Duration(cfg.EchoWindowMs) * time.Millisecond,
+	}
+}
+
+// reconfigured 在线路参数修改后按新波特率重新计算单字符发送时间
+func (e *echoCanceller) reconfigured(cfg config.Port) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.charTime = charTime(cfg)
+	e.mu.Unlock()
+}
+
+// expect 在写入串口前登记即将发送的字节
+func (e *echoCanceller) expect(b []byte) {
+	if e == nil || len(b) == 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	if now.After(e.deadline) {
+		e.pending = e.pending[:0]
+	}
+	e.pending = append(e.pending, b...)
+	e.deadline = now.Add(time.Duration(len(e.pending))*e.charTime + e.window)
+}
+
+// cancel 在写入失败时撤销登记
+func (e *echoCanceller) cancel() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.pending = e.pending[:0]
+	e.mu.Unlock()
+}
+
+// strip 从收到的 b 中剥离回显前缀，返回剩余的有效字节
+func (e *echoCanceller) strip(b []byte) []byte {
+	if e == nil {
+		return b
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) == 0 {
+		return b
+	}
+	if time.Now().After(e.deadline) {
+		// 窗口期内没有收到回显（接收器可能已关闭），不再等待
+		e.pending = e.pending[:0]
+		return b
+	}
+	i := 0
+	for i < len(b) && len(e.pending) > 0 {
+		if b[i] != e.pending[0] {
+			e.collisions++
+			fmt.Printf("⚠️ echo mismatch: sent 0x%02X, got 0x%02X, bus collision #%d\n", e.pending[0], b[i], e.collisions)
+			e.pending = e.pending[:0]
+			break
+		}
+		e.pending = e.pending[1:]
+		i++
+	}
+	return b[i:]
+}
+
+// read 调用 readFn 读取数据并剥离回显，只剩回显时继续读
+func (e *echoCanceller) read(p []byte, readFn func([]byte) (int, error)) (int, error) {
+	for {
+		n, err := readFn(p)
+		if e == nil || n == 0 {
+			return n, err
+		}
+		rest := e.strip(p[:n])
+		m := copy(p, rest)
+		if m > 0 || err != nil {
+			return m, err
+		}
+	}
+}
+
+// Collisions 返回累计的总线冲突次数
+func (e *echoCanceller) Collisions() uint64 {
+	if e == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.collisions
+}
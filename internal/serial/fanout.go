@@ -0,0 +1,156 @@
+package serial
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// fanoutQueueLen 是每个 pty 待写入的数据块上限；无人读取从端时超出部分被丢弃
+const fanoutQueueLen = 64
+
+// fanoutPTY 是扇出中的一个伪终端
+type fanoutPTY struct {
+	master *os.File
+	slave  *os.File // 自身持有从端，遗留程序反复打开/关闭时主端不会挂断
+	path   string
+	link   string
+	out    chan []byte
+	drop   bool // 正在丢弃数据，只在开始丢弃时打印一次
+}
+
+// Fanout 为一个物理端口创建若干 pty，供只能打开 tty 的遗留程序使用：
+// 物理端口收到的字节复制到每个 pty，各 pty 写入的字节经统一写入路径（与 MQTT 命令相同）串行写入物理端口。
+// nil 表示未配置扇出，所有方法直接放行。
+type Fanout struct {
+	name string
+	cfg  []config.FanoutPTY
+
+	mu   sync.Mutex
+	ptys []*fanoutPTY
+}
+
+// NewFanout 按端口的 fanout 配置创建扇出；未配置时返回 nil
+func NewFanout(pc config.Port) *Fanout {
+	if len(pc.Fanout) == 0 {
+		return nil
+	}
+	return &Fanout{name: pc.Name, cfg: pc.Fanout}
+}
+
+// Start 分配全部 pty，把各 pty 写入的数据交给 w
+func (f *Fanout) Start(w io.Writer) error {
+	if f == nil {
+		return nil
+	}
+	for _, c := range f.cfg {
+		master, slave, path, err := openPTY()
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("fanout pty: %w", err)
+		}
+		p := &fanoutPTY{master: master, slave: slave, path: path, out: make(chan []byte, fanoutQueueLen)}
+		f.mu.Lock()
+		f.ptys = append(f.ptys, p)
+		f.mu.Unlock()
+		if c.Link != "" {
+			if err := replaceSymlink(path, c.Link); err != nil {
+				f.Close()
+				return fmt.Errorf("link %s → %s: %w", c.Link, path, err)
+			}
+			p.link = c.Link
+		}
+		fmt.Printf("🧪 [%s] fanout pty %s%s\n", f.name, path, linkSuffix(p.link))
+		go f.relayIn(p, w)
+		go p.relayOut()
+	}
+	return nil
+}
+
+// relayIn 把遗留程序写入 pty 的字节写入物理端口
+func (f *Fanout) relayIn(p *fanoutPTY, w io.Writer) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := p.master.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				fmt.Printf("⚠️ [%s] fanout %s: write to port failed: %v\n", f.name, p.path, werr)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// relayOut 把物理端口收到的数据写入 pty 主端
+func (p *fanoutPTY) relayOut() {
+	for b := range p.out {
+		if _, err := p.master.Write(b); err != nil {
+			for range p.out {
+			}
+			return
+		}
+	}
+}
+
+// Broadcast 把物理端口收到的字节复制到每个 pty；从端无人读取导致积压时丢弃
+func (f *Fanout) Broadcast(b []byte) {
+	if f == nil || len(b) == 0 {
+		return
+	}
+	chunk := append([]byte(nil), b...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range f.ptys {
+		select {
+		case p.out <- chunk:
+			p.drop = false
+		default:
+			if !p.drop {
+				fmt.Printf("⚠️ [%s] fanout %s not being read, dropping data\n", f.name, p.path)
+				p.drop = true
+			}
+		}
+	}
+}
+
+// Paths 返回供其他程序打开的路径（有符号链接时为链接）
+func (f *Fanout) Paths() []string {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := make([]string, 0, len(f.ptys))
+	for _, p := range f.ptys {
+		if p.link != "" {
+			paths = append(paths, p.link)
+		} else {
+			paths = append(paths, p.path)
+		}
+	}
+	return paths
+}
+
+// Close 关闭全部 pty 并删除符号链接
+func (f *Fanout) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range f.ptys {
+		if p.link != "" {
+			os.Remove(p.link)
+		}
+		close(p.out)
+		p.slave.Close()
+		p.master.Close()
+	}
+	f.ptys = nil
+	return nil
+}
@@ -0,0 +1,161 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// dirPin 是 RS-485 方向控制用的单个输出引脚，
+// active 表示逻辑有效电平（低电平有效由具体实现换算）
+type dirPin interface {
+	Set(active bool) error
+	Close() error
+}
+
+// dirControl 负责 RS-485 收发方向切换：
+//   - DE 有效时驱动器向总线发送
+//   - 可选的 RE 引脚有效时接收器工作，发送期间除非 rxDuringTx 否则关闭
+type dirControl struct {
+	de         dirPin
+	re         dirPin // 可为 nil
+	rxDuringTx bool
+}
+
+// openDirControl 按配置打开 DE/RE 引脚并置为接收状态
+func openDirControl(cfg config.Port) (*dirControl, error) {
+	d := &dirControl{rxDuringTx: cfg.RxDuringTx}
+	var err error
+	if cfg.DE != nil {
+		if d.de, err = openLinePin(cfg.DE, cfg.Name+"-de"); err != nil {
+			return nil, fmt.Errorf("request DE %s:%d: %w", cfg.DE.Chip, cfg.DE.Line, err)
+		}
+		if cfg.RE != nil {
+			if d.re, err = openLinePin(cfg.RE, cfg.Name+"-re"); err != nil {
+				d.de.Close()
+				return nil, fmt.Errorf("request RE %s:%d: %w", cfg.RE.Chip, cfg.RE.Line, err)
+			}
+		}
+	} else {
+		if d.de, err = openSysfsPin(*cfg.DEPin); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.receive(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// transmit 切到发送
+func (d *dirControl) transmit() error {
+	if d.re != nil && !d.rxDuringTx {
+		if err := d.re.Set(false); err != nil {
+			return fmt.Errorf("GPIO RE off failed: %w", err)
+		}
+	}
+	if err := d.de.Set(true); err != nil {
+		return fmt.Errorf("GPIO DE on failed: %w", err)
+	}
+	return nil
+}
+
+// receive 切回接收
+func (d *dirControl) receive() error {
+	if err := d.de.Set(false); err != nil {
+		return fmt.Errorf("GPIO DE off failed: %w", err)
+	}
+	if d.re != nil {
+		if err := d.re.Set(true); err != nil {
+			return fmt.Errorf("GPIO RE on failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close 释放所有引脚
+func (d *dirControl) Close() error {
+	var firstErr error
+	for _, p := range []dirPin{d.de, d.re} {
+		if p == nil {
+			continue
+		}
+		if err := p.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// openLinePin 打开 gpiochip 上的一个输出线，低电平有效由实现换算；
+// 测试中替换为内存实现以验证方向切换顺序
+var openLinePin = func(l *config.GPIOLine, consumer string) (dirPin, error) {
+	return openCdevPin(l.Chip, l.Line, l.ActiveLow, consumer)
+}
+
+// -------- sysfs 引脚（旧方式） --------
+
+// sysfsPin 通过 /sys/class/gpio 控制引脚，高电平为有效
+type sysfsPin struct {
+	f *os.File
+}
+
+// openSysfsPin 导出 GPIO 并设为输出
+func openSysfsPin(pin int) (*sysfsPin, error) {
+	if err := exportGPIO(pin); err != nil {
+		return nil, fmt.Errorf("export GPIO %d failed: %w", pin, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := setGPIODirection(pin, "out"); err != nil {
+		return nil, fmt.Errorf("set GPIO %d direction: %w", pin, err)
+	}
+	f, err := openGPIOValue(pin)
+	if err != nil {
+		return nil, fmt.Errorf("open GPIO %d value: %w", pin, err)
+	}
+	return &sysfsPin{f: f}, nil
+}
+
+// Set 写入 "1"/"0"
+func (s *sysfsPin) Set(active bool) error {
+	v := "0"
+	if active {
+		v = "1"
+	}
+	_, err := s.f.WriteString(v)
+	return err
+}
+
+// Close 关闭 value 节点
+func (s *sysfsPin) Close() error {
+	return s.f.Close()
+}
+
+func exportGPIO(pin int) error {
+	f, err := os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _ = f.WriteString(fmt.Sprint(pin)) // 若已导出则忽略错误
+	return nil
+}
+
+func setGPIODirection(pin int, dir string) error {
+	path := fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(dir)
+	return err
+}
+
+func openGPIOValue(pin int) (*os.File, error) {
+	path := fmt.Sprintf("/sys/class/gpio/gpio%d/value", pin)
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
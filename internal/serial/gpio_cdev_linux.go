@@ -0,0 +1,117 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// GPIO v2 uAPI（linux/gpio.h）常量
+const (
+	gpioV2LinesMax         = 64
+	gpioMaxNameSize        = 32
+	gpioV2LineNumAttrsMax  = 10
+	gpioV2LineFlagActiveLo = 1 << 1
+	gpioV2LineFlagOutput   = 1 << 3
+	gpioV2AttrOutputValues = 2
+)
+
+type gpioV2LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	Value   uint64 // flags / values / debounce_period_us 联合体
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [gpioV2LineNumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [gpioV2LinesMax]uint32
+	Consumer        [gpioMaxNameSize]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+// _IOWR(0xB4, nr, size)
+func gpioIOWR(nr, size uintptr) uint {
+	return uint(3<<30 | size<<16 | 0xB4<<8 | nr)
+}
+
+var (
+	gpioV2GetLineIoctl   = gpioIOWR(0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2SetValuesIoctl = gpioIOWR(0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+// cdevPin 是通过 /dev/gpiochipN 行请求得到的输出线，
+// 低电平有效由内核按 GPIO_V2_LINE_FLAG_ACTIVE_LOW 换算
+type cdevPin struct {
+	f *os.File
+}
+
+// openCdevPin 在 chip 上请求 line 为输出，初始为无效电平
+func openCdevPin(chip string, line int, activeLow bool, consumer string) (*cdevPin, error) {
+	path := chip
+	if !filepath.IsAbs(path) {
+		path = filepath.Join("/dev", chip)
+	}
+	cf, err := os.OpenFile(path, os.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer cf.Close()
+
+	var req gpioV2LineRequest
+	req.Offsets[0] = uint32(line)
+	req.NumLines = 1
+	copy(req.Consumer[:gpioMaxNameSize-1], consumer)
+	req.Config.Flags = gpioV2LineFlagOutput
+	if activeLow {
+		req.Config.Flags |= gpioV2LineFlagActiveLo
+	}
+	req.Config.NumAttrs = 1
+	req.Config.Attrs[0] = gpioV2LineConfigAttribute{
+		Attr: gpioV2LineAttribute{ID: gpioV2AttrOutputValues, Value: 0},
+		Mask: 1,
+	}
+	if err := ioctlPtr(int(cf.Fd()), gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("GPIO_V2_GET_LINE: %w", err)
+	}
+	return &cdevPin{f: os.NewFile(uintptr(req.Fd), fmt.Sprintf("%s:%d", chip, line))}, nil
+}
+
+// Set 设置逻辑电平
+func (c *cdevPin) Set(active bool) error {
+	v := gpioV2LineValues{Mask: 1}
+	if active {
+		v.Bits = 1
+	}
+	if err := ioctlPtr(int(c.f.Fd()), gpioV2SetValuesIoctl, unsafe.Pointer(&v)); err != nil {
+		return fmt.Errorf("GPIO_V2_LINE_SET_VALUES: %w", err)
+	}
+	return nil
+}
+
+// Close 释放行请求
+func (c *cdevPin) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,167 @@
+package serial
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// pinLog 按发生顺序记录引脚的物理电平变化与串口操作
+type pinLog struct {
+	events []string
+}
+
+func (l *pinLog) add(format string, args ...interface{}) {
+	l.events = append(l.events, fmt.Sprintf(format, args...))
+}
+
+// fakePin 像内核 ACTIVE_LOW 标志那样把逻辑电平换算为物理电平
+type fakePin struct {
+	name      string
+	activeLow bool
+	log       *pinLog
+}
+
+func (p *fakePin) Set(active bool) error {
+	level := 0
+	if active != p.activeLow {
+		level = 1
+	}
+	p.log.add("%s=%d", p.name, level)
+	return nil
+}
+
+func (p *fakePin) Close() error {
+	p.log.add("%s closed", p.name)
+	return nil
+}
+
+// fakeRaw 记录写入，供 RS485Port 在 gpio 模式下使用
+type fakeRaw struct {
+	log *pinLog
+}
+
+func (f *fakeRaw) Read(p []byte) (int, error) { return 0, nil }
+func (f *fakeRaw) Close() error               { return nil }
+func (f *fakeRaw) Fd() uintptr                { return 0 }
+func (f *fakeRaw) Write(p []byte) (int, error) {
+	f.log.add("write %X", p)
+	return len(p), nil
+}
+
+// useFakePins 把 openLinePin 替换为内存实现，测试结束时恢复
+func useFakePins(t *testing.T, log *pinLog) {
+	t.Helper()
+	orig := openLinePin
+	openLinePin = func(l *config.GPIOLine, consumer string) (dirPin, error) {
+		return &fakePin{name: consumer, activeLow: l.ActiveLow, log: log}, nil
+	}
+	t.Cleanup(func() { openLinePin = orig })
+}
+
+func gpioPortConfig(rxDuringTx bool) config.Port {
+	return config.Port{
+		Name:       "P",
+		RS485Mode:  config.RS485GPIO,
+		Baudrate:   9600,
+		DataBits:   8,
+		Parity:     config.ParityNone,
+		StopBits:   1,
+		DE:         &config.GPIOLine{Chip: "gpiochip0", Line: 17},
+		RE:         &config.GPIOLine{Chip: "gpiochip0", Line: 18, ActiveLow: true},
+		RxDuringTx: rxDuringTx,
+	}
+}
+
+func TestDirControlOpensInReceive(t *testing.T) {
+	log := &pinLog{}
+	useFakePins(t, log)
+	if _, err := openDirControl(gpioPortConfig(false)); err != nil {
+		t.Fatal(err)
+	}
+	// DE 无效（高电平有效 → 0），/RE 有效（低电平有效 → 0）
+	want := []string{"P-de=0", "P-re=0"}
+	if !reflect.DeepEqual(log.events, want) {
+		t.Fatalf("events = %v, want %v", log.events, want)
+	}
+}
+
+func TestDirControlTransmitOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		rxDuringTx bool
+		want       []string
+	}{
+		// 先关接收器再使能驱动器；切回时先释放总线再打开接收器
+		{"exclusive", false, []string{"P-re=1", "P-de=1", "P-de=0", "P-re=0"}},
+		// rxDuringTx 时接收器始终打开
+		{"rxDuringTx", true, []string{"P-de=1", "P-de=0", "P-re=0"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			log := &pinLog{}
+			useFakePins(t, log)
+			d, err := openDirControl(gpioPortConfig(tc.rxDuringTx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.events = nil
+			if err := d.transmit(); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.receive(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(log.events, tc.want) {
+				t.Fatalf("events = %v, want %v", log.events, tc.want)
+			}
+		})
+	}
+}
+
+func TestDirControlActiveLowDE(t *testing.T) {
+	log := &pinLog{}
+	useFakePins(t, log)
+	cfg := gpioPortConfig(false)
+	cfg.DE.ActiveLow = true
+	cfg.RE = nil
+	d, err := openDirControl(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.transmit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"P-de=1", "P-de=0"}
+	if !reflect.DeepEqual(log.events, want) {
+		t.Fatalf("events = %v, want %v", log.events, want)
+	}
+}
+
+func TestRS485WriteRestoresReceiveAfterDrain(t *testing.T) {
+	log := &pinLog{}
+	useFakePins(t, log)
+	origDrain := drainTxFn
+	drainTxFn = func(fd int, charTime time.Duration) error {
+		log.add("drain")
+		return nil
+	}
+	t.Cleanup(func() { drainTxFn = origDrain })
+
+	cfg := gpioPortConfig(false)
+	d, err := openDirControl(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &RS485Port{cfg: cfg, port: &fakeRaw{log: log}, dir: d}
+	log.events = nil
+	if _, err := r.Write([]byte{0x68, 0x16}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"P-re=1", "P-de=1", "write 6816", "drain", "P-de=0", "P-re=0"}
+	if !reflect.DeepEqual(log.events, want) {
+		t.Fatalf("events = %v, want %v", log.events, want)
+	}
+}
@@ -0,0 +1,172 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// errHotplugClosed 表示合成事件源已关闭
+var errHotplugClosed = errors.New("uevent source closed")
+
+// UEvent 是一条内核 kobject uevent
+type UEvent struct {
+	Action    string            // add/remove/change/bind/unbind...
+	DevPath   string            // /devices/... 路径
+	Subsystem string            // 如 tty
+	DevName   string            // 设备节点名（相对 /dev），如 ttyUSB0
+	Env       map[string]string // 全部 KEY=VALUE
+}
+
+// UEventSource 提供 uevent 流；Linux 上由 netlink 套接字实现，测试时可注入合成事件
+type UEventSource interface {
+	// Next 阻塞直到下一条事件；源关闭后返回错误
+	Next() (UEvent, error)
+	Close() error
+}
+
+// ParseUEvent 解析内核广播的 uevent 报文：
+// "ACTION@DEVPATH\0ACTION=add\0DEVPATH=...\0SUBSYSTEM=tty\0DEVNAME=ttyUSB0\0..."
+// udev 转发的 libudev 格式报文不以 "@" 头开始，会被拒绝
+func ParseUEvent(b []byte) (UEvent, error) {
+	fields := bytes.Split(b, []byte{0})
+	head := string(fields[0])
+	if !strings.Contains(head, "@") {
+		return UEvent{}, fmt.Errorf("not a kernel uevent: %q", head)
+	}
+	ev := UEvent{Env: make(map[string]string, len(fields))}
+	for _, f := range fields[1:] {
+		k, v, ok := strings.Cut(string(f), "=")
+		if !ok {
+			continue
+		}
+		ev.Env[k] = v
+	}
+	ev.Action = ev.Env["ACTION"]
+	ev.DevPath = ev.Env["DEVPATH"]
+	ev.Subsystem = ev.Env["SUBSYSTEM"]
+	ev.DevName = ev.Env["DEVNAME"]
+	if ev.Action == "" {
+		// 头部形如 add@/devices/...
+		ev.Action, ev.DevPath, _ = strings.Cut(head, "@")
+	}
+	return ev, nil
+}
+
+// ChanSource 是以通道投递事件的 UEventSource，用于注入合成事件
+type ChanSource struct {
+	C    chan UEvent
+	once sync.Once
+	done chan struct{}
+}
+
+// NewChanSource 创建合成事件源
+func NewChanSource() *ChanSource {
+	return &ChanSource{C: make(chan UEvent), done: make(chan struct{})}
+}
+
+// Next 返回下一条投递到 C 的事件
+func (c *ChanSource) Next() (UEvent, error) {
+	select {
+	case ev := <-c.C:
+		return ev, nil
+	case <-c.done:
+		return UEvent{}, errHotplugClosed
+	}
+}
+
+// Close 结束事件流
+func (c *ChanSource) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+// hotplugEntry 是一个受热插拔管理的端口
+type hotplugEntry struct {
+	cfg config.Port
+	sup *Supervisor
+}
+
+// Hotplug 监听 tty 子系统的 uevent：
+//   - add：唤醒与事件设备匹配的断开端口立即打开
+//   - remove：已连接端口探测失效后关闭并等待设备重新出现
+type Hotplug struct {
+	src      UEventSource
+	resolver SysfsResolver
+
+	mu      sync.Mutex
+	entries []hotplugEntry
+}
+
+// NewHotplug 基于给定事件源创建热插拔管理器
+func NewHotplug(src UEventSource) *Hotplug {
+	return &Hotplug{src: src, resolver: defaultResolver}
+}
+
+// Add 登记需要随设备插拔打开/关闭的端口
+func (h *Hotplug) Add(cfg config.Port, sup *Supervisor) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, hotplugEntry{cfg: cfg, sup: sup})
+}
+
+// Run 处理事件直到事件源关闭
+func (h *Hotplug) Run() error {
+	for {
+		ev, err := h.src.Next()
+		if err != nil {
+			return err
+		}
+		h.Handle(ev)
+	}
+}
+
+// Close 关闭事件源，Run 随之返回
+func (h *Hotplug) Close() error {
+	return h.src.Close()
+}
+
+// Handle 处理一条事件，只关心 tty 子系统的 add/remove
+func (h *Hotplug) Handle(ev UEvent) {
+	if ev.Subsystem != "tty" || ev.DevName == "" {
+		return
+	}
+	h.mu.Lock()
+	entries := append([]hotplugEntry(nil), h.entries...)
+	h.mu.Unlock()
+
+	switch ev.Action {
+	case "add":
+		for _, e := range entries {
+			if !e.sup.Connected() && h.matches(e.cfg, ev.DevName) {
+				fmt.Printf("🔌 [%s] %s added\n", e.cfg.Name, ev.DevName)
+				e.sup.Plugged()
+			}
+		}
+	case "remove":
+		// 设备已从 sysfs 消失，无法再按名称反查，逐个探测已连接端口
+		for _, e := range entries {
+			e.sup.Unplugged()
+		}
+	}
+}
+
+// matches 判断新出现的 tty 是否就是端口配置的设备
+func (h *Hotplug) matches(cfg config.Port, devName string) bool {
+	if cfg.Match != nil {
+		dev, err := h.resolver.Resolve(cfg.Match)
+		return err == nil && filepath.Base(dev) == devName
+	}
+	if filepath.Base(cfg.Device) == devName {
+		return true
+	}
+	// /dev/serial/by-id 等符号链接由 udev 在内核事件之后创建，此时可能尚不存在，
+	// 漏掉的由 Supervisor 的退避重试兜底
+	real, err := filepath.EvalSymlinks(cfg.Device)
+	return err == nil && filepath.Base(real) == devName
+}
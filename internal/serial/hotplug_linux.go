@@ -0,0 +1,49 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// NetlinkSource 通过 NETLINK_KOBJECT_UEVENT 套接字接收内核广播的 uevent
+type NetlinkSource struct {
+	f   *os.File
+	buf []byte
+}
+
+// NewNetlinkSource 订阅内核 uevent 组播组
+func NewNetlinkSource() (*NetlinkSource, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	// 组 1 为内核直接广播的事件（组 2 是 udev 处理后转发的）
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+	// 非阻塞 fd 交给 runtime poller，Close 可打断阻塞中的 Next
+	return &NetlinkSource{f: os.NewFile(uintptr(fd), "uevent"), buf: make([]byte, 64*1024)}, nil
+}
+
+// Next 读取并解析下一条内核 uevent，跳过无法解析的报文
+func (n *NetlinkSource) Next() (UEvent, error) {
+	for {
+		c, err := n.f.Read(n.buf)
+		if err != nil {
+			return UEvent{}, err
+		}
+		ev, err := ParseUEvent(n.buf[:c])
+		if err != nil {
+			continue
+		}
+		return ev, nil
+	}
+}
+
+// Close 关闭套接字
+func (n *NetlinkSource) Close() error {
+	return n.f.Close()
+}
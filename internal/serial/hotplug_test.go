@@ -0,0 +1,181 @@
+package serial
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// hotplugPort 是可控制设备是否存在的假端口
+type hotplugPort struct {
+	name string
+
+	mu      sync.Mutex
+	present bool
+	opens   int
+}
+
+func (p *hotplugPort) setPresent(v bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.present = v
+}
+
+func (p *hotplugPort) openCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.opens
+}
+
+func (p *hotplugPort) Open() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.opens++
+	if !p.present {
+		return ErrNoDevice
+	}
+	return nil
+}
+
+func (p *hotplugPort) Probe() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.present {
+		return errors.New("device gone")
+	}
+	return nil
+}
+
+func (p *hotplugPort) Close() error                  { return nil }
+func (p *hotplugPort) Read(b []byte) (int, error)    { return 0, nil }
+func (p *hotplugPort) Write(b []byte) (int, error)   { return len(b), nil }
+func (p *hotplugPort) Name() string                  { return p.name }
+func (p *hotplugPort) ReadFrame() ([]byte, error)    { return nil, nil }
+func (p *hotplugPort) WriteFrame(frame []byte) error { return nil }
+
+// watched 是受热插拔管理的一个端口及其状态变化
+type watched struct {
+	cfg    config.Port
+	port   *hotplugPort
+	sup    *Supervisor
+	states chan bool
+}
+
+// newWatched 创建监管器；退避间隔足够长，只有热插拔事件能让它在测试期间重新打开
+func newWatched(cfg config.Port, present bool) *watched {
+	w := &watched{cfg: cfg, port: &hotplugPort{name: cfg.Name, present: present}, states: make(chan bool, 8)}
+	w.sup = NewSupervisor(w.port, time.Hour, time.Hour)
+	w.sup.OnState(func(connected bool, err error) { w.states <- connected })
+	return w
+}
+
+// expect 等待下一次状态变化
+func (w *watched) expect(t *testing.T, connected bool) {
+	t.Helper()
+	select {
+	case got := <-w.states:
+		if got != connected {
+			t.Fatalf("%s: state connected=%v, want %v", w.port.name, got, connected)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("%s: no state change, want connected=%v", w.port.name, connected)
+	}
+}
+
+// expectNone 确认一段时间内没有状态变化
+func (w *watched) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-w.states:
+		t.Fatalf("%s: unexpected state change connected=%v", w.port.name, got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// startHotplug 以合成事件源运行热插拔管理器，USB match 按 testdata/sysfs 解析
+func startHotplug(t *testing.T, ports ...*watched) *ChanSource {
+	t.Helper()
+	src := NewChanSource()
+	h := NewHotplug(src)
+	h.resolver = fixtureResolver
+	for _, w := range ports {
+		h.Add(w.cfg, w.sup)
+	}
+	done := make(chan struct{})
+	go func() {
+		h.Run()
+		close(done)
+	}()
+	t.Cleanup(func() {
+		h.Close()
+		<-done
+		for _, w := range ports {
+			w.sup.Close()
+		}
+	})
+	return src
+}
+
+func ttyEvent(action, devName string) UEvent {
+	return UEvent{Action: action, Subsystem: "tty", DevName: devName, DevPath: "/devices/virtual/tty/" + devName}
+}
+
+func TestHotplugAddPlugsMatchingPortOnly(t *testing.T) {
+	byName := newWatched(config.Port{Name: "byName", Device: "/dev/ttyACM0"}, false)
+	byMatch := newWatched(config.Port{Name: "byMatch", Match: &config.USBMatch{Serial: "FT2232A", Interface: intPtr(1)}}, false)
+	other := newWatched(config.Port{Name: "other", Device: "/dev/ttyACM1"}, false)
+	for _, w := range []*watched{byName, byMatch, other} {
+		w.sup.Await(ErrNoDevice)
+		w.expect(t, false)
+	}
+	src := startHotplug(t, byName, byMatch, other)
+
+	// 非 tty 子系统的事件被忽略
+	byName.port.setPresent(true)
+	src.C <- UEvent{Action: "add", Subsystem: "usb", DevName: "ttyACM0"}
+	byName.expectNone(t)
+
+	src.C <- ttyEvent("add", "ttyACM0")
+	byName.expect(t, true)
+
+	// 按 sysfs 解析 match：ttyUSB2 是 FT2232A 的接口 1
+	byMatch.port.setPresent(true)
+	src.C <- ttyEvent("add", "ttyUSB1")
+	byMatch.expectNone(t)
+	src.C <- ttyEvent("add", "ttyUSB2")
+	byMatch.expect(t, true)
+
+	other.expectNone(t)
+	if n := other.port.openCount(); n != 0 {
+		t.Fatalf("unmatched port reopened %d times", n)
+	}
+}
+
+func TestHotplugRemoveUnplugsVanishedPortOnly(t *testing.T) {
+	gone := newWatched(config.Port{Name: "gone", Device: "/dev/ttyUSB0"}, true)
+	stays := newWatched(config.Port{Name: "stays", Device: "/dev/ttyUSB1"}, true)
+	for _, w := range []*watched{gone, stays} {
+		if err := w.sup.Start(); err != nil {
+			t.Fatal(err)
+		}
+		w.expect(t, true)
+	}
+	src := startHotplug(t, gone, stays)
+
+	gone.port.setPresent(false)
+	src.C <- ttyEvent("remove", "ttyUSB0")
+	gone.expect(t, false)
+	stays.expectNone(t)
+	if !stays.sup.Connected() {
+		t.Fatal("port whose device is still present was closed")
+	}
+
+	// 设备重新出现后只重新打开断开的端口
+	gone.port.setPresent(true)
+	src.C <- ttyEvent("add", "ttyUSB0")
+	gone.expect(t, true)
+	stays.expectNone(t)
+}
@@ -0,0 +1,122 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"github.com/tarm/serial"
+	"golang.org/x/sys/unix"
+)
+
+// rawPort 是 UART/RS-232/RS-485 共用的底层串口句柄，
+// tarm 与 termios 两种后端都实现它
+type rawPort interface {
+	io.ReadWriteCloser
+	// Fd 返回底层 tty 的文件描述符，用于 ioctl
+	Fd() uintptr
+}
+
+// openSerialPort 按 cfg.Backend 选择底层驱动打开串口，并设置完整的线路参数
+// 配置了 match 时，每次打开（含重新打开）都重新解析设备节点；
+// 打开前先取得 UUCP 锁文件，关闭时释放
+func openSerialPort(cfg config.Port) (rawPort, error) {
+	dev, err := resolveDevice(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Match != nil {
+		fmt.Printf("🔎 [%s] %s → %s\n", cfg.Name, cfg.DeviceLabel(), dev)
+	}
+	cfg.Device = dev
+	lock, err := lockDevice(dev, cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	var p rawPort
+	switch cfg.Backend {
+	case config.BackendTermios:
+		p, err = openTermios(cfg)
+	case config.BackendTarm, "":
+		p, err = openTarm(cfg)
+	default:
+		err = fmt.Errorf("unknown backend %s", cfg.Backend)
+	}
+	if err != nil {
+		lock.release()
+		if errors.Is(err, unix.EBUSY) {
+			return nil, busyError(dev, err)
+		}
+		return nil, err
+	}
+	if lock == nil {
+		return p, nil
+	}
+	return &lockedPort{rawPort: p, lock: lock}, nil
+}
+
+// tarmPort 由 tarm/serial 打开并设置波特率，读写和 ioctl 走另一个由轮询器驱动的非阻塞句柄
+// （tarm 的句柄是阻塞的且不暴露文件描述符，termios 属于 tty 设备本身，对所有句柄生效）
+type tarmPort struct {
+	*pollTTY
+	port *serial.Port
+}
+
+// openTarm 用 tarm/serial 打开串口并设置波特率，
+// 数据位/校验/停止位/流控随后统一写入 termios
+func openTarm(cfg config.Port) (*tarmPort, error) {
+	sc := &serial.Config{
+		Name: cfg.Device,
+		Baud: cfg.Baudrate,
+	}
+	p, err := serial.OpenPort(sc)
+	if err != nil {
+		return nil, err
+	}
+	ctl, err := openPollTTY(cfg.Device)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	if err := setLineSettings(int(ctl.Fd()), cfg); err != nil {
+		ctl.Close()
+		p.Close()
+		return nil, fmt.Errorf("apply line settings: %w", err)
+	}
+	if cfg.Exclusive {
+		// TIOCEXCL 作用于 tty 本身，经控制句柄设置即可
+		if err := unix.IoctlSetInt(int(ctl.Fd()), unix.TIOCEXCL, 0); err != nil {
+			ctl.Close()
+			p.Close()
+			return nil, fmt.Errorf("TIOCEXCL: %w", err)
+		}
+	}
+	return &tarmPort{pollTTY: ctl, port: p}, nil
+}
+
+// Close 关闭非阻塞句柄（打断阻塞中的 Read）和 tarm 句柄
+func (t *tarmPort) Close() error {
+	t.pollTTY.Close()
+	return t.port.Close()
+}
+
+// charBits 返回按当前线路参数发送一个字符所占的位数（起始位+数据位+校验位+停止位）
+func charBits(cfg config.Port) int {
+	bits := 1 + cfg.DataBits
+	if cfg.Parity != config.ParityNone {
+		bits++
+	}
+	if cfg.StopBits > 1 {
+		bits += 2
+	} else {
+		bits++
+	}
+	return bits
+}
+
+// charTime 返回按当前波特率发送一个字符所需的时间
+func charTime(cfg config.Port) time.Duration {
+	return time.Duration(charBits(cfg)) * time.Second / time.Duration(cfg.Baudrate)
+}
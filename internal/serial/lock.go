@@ -0,0 +1,235 @@
+package serial
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// ErrPortLocked 表示设备已被其他进程占用（UUCP 锁文件或 TIOCEXCL）
+var ErrPortLocked = errors.New("serial device is in use")
+
+// lockDir 是 UUCP 锁文件目录，config.LockNone 表示关闭
+var lockDir = config.DefaultLockDir
+
+// heldLocks 记录本进程内各锁文件由哪个端口持有，锁文件中的 PID 无法区分同一进程的两个端口
+var (
+	heldMu    sync.Mutex
+	heldLocks = make(map[string]string)
+)
+
+// SetLockDir 设置 UUCP 锁文件目录，传入 config.LockNone 关闭锁文件
+func SetLockDir(dir string) {
+	lockDir = dir
+}
+
+// deviceLock 是本进程持有的 /var/lock/LCK..ttyXXX 锁文件
+type deviceLock struct {
+	path string
+}
+
+// hold 登记端口对锁文件的持有，已被本进程其他端口持有时报错
+func hold(path, dev, owner string) (*deviceLock, error) {
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	if other, ok := heldLocks[path]; ok {
+		return nil, fmt.Errorf("%w: %s already opened by port %s", ErrPortLocked, dev, other)
+	}
+	heldLocks[path] = owner
+	return &deviceLock{path: path}, nil
+}
+
+// lockedPort 在关闭底层句柄后释放锁文件
+type lockedPort struct {
+	rawPort
+	lock *deviceLock
+}
+
+// Close 关闭串口并删除锁文件
+func (l *lockedPort) Close() error {
+	err := l.rawPort.Close()
+	l.lock.release()
+	return err
+}
+
+// maxLockAttempts 限制锁文件被他人并发创建/删除时的重试次数
+const maxLockAttempts = 3
+
+// lockName 由设备节点得到锁文件名：先解析符号链接（如 /dev/serial/by-id/...），
+// 与 pppd/minicom 对同一设备使用相同的名字
+func lockName(dev string) string {
+	if real, err := filepath.EvalSymlinks(dev); err == nil {
+		dev = real
+	}
+	name := strings.TrimPrefix(filepath.Clean(dev), "/dev/")
+	return "LCK.." + strings.ReplaceAll(name, "/", "_")
+}
+
+// lockDevice 按 UUCP 约定创建锁文件：已被存活进程持有时返回 ErrPortLocked 并注明进程，
+// 只有读出 PID 且 kill(pid, 0) 确认持有者已退出的陈旧锁才会被清除，读不出 PID 的锁按占用处理；
+// 锁目录不可写时仍检查他人的锁，但不阻止打开
+func lockDevice(dev, owner string) (*deviceLock, error) {
+	if lockDir == "" || lockDir == config.LockNone {
+		return nil, nil
+	}
+	path := filepath.Join(lockDir, lockName(dev))
+	self := os.Getpid()
+	heldMu.Lock()
+	other, busy := heldLocks[path]
+	heldMu.Unlock()
+	if busy {
+		return nil, fmt.Errorf("%w: %s already opened by port %s", ErrPortLocked, dev, other)
+	}
+	for attempt := 0; ; attempt++ {
+		err := createLock(path, self)
+		if err == nil {
+			return hold(path, dev, owner)
+		}
+		pid, ok := readLockPID(path)
+		if !errors.Is(err, os.ErrExist) {
+			if ok && pid != self && processAlive(pid) {
+				return nil, lockedError(dev, pid)
+			}
+			fmt.Printf("⚠️ cannot create lock %s, opening without it: %v\n", path, err)
+			return nil, nil
+		}
+		switch {
+		case !ok:
+			if _, serr := os.Stat(path); errors.Is(serr, os.ErrNotExist) && attempt < maxLockAttempts {
+				// 持有者恰好释放了锁，重新创建
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s has lock %s with unreadable pid", ErrPortLocked, dev, path)
+		case pid == self:
+			// 本进程之前留下的锁（如关闭时未能删除），直接沿用
+			return hold(path, dev, owner)
+		case processAlive(pid):
+			return nil, lockedError(dev, pid)
+		case attempt >= maxLockAttempts:
+			return nil, fmt.Errorf("lock %s still present after removing stale lock", path)
+		}
+		fmt.Printf("🧹 removing stale lock %s (pid %d)\n", path, pid)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("remove stale lock %s: %w", path, err)
+		}
+	}
+}
+
+// createLock 先写临时文件再 link 到锁文件名，保证其他进程看不到写了一半的 PID；
+// 锁文件已存在时返回 os.ErrExist
+func createLock(path string, pid int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-LCK.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// HDB UUCP 格式：10 位右对齐的十进制 PID 加换行
+	_, err = fmt.Fprintf(tmp, "%10d\n", pid)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Link(tmp.Name(), path)
+}
+
+// readLockPID 读取锁文件中的 PID，兼容 ASCII 与旧式 4 字节二进制格式
+func readLockPID(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+		return pid, pid > 0
+	}
+	if len(data) == 4 {
+		pid := int(int32(binary.LittleEndian.Uint32(data)))
+		return pid, pid > 0
+	}
+	return 0, false
+}
+
+// processAlive 判断进程是否存在；只有 ESRCH 确认进程已退出，EPERM 表示存在但属于其他用户
+func processAlive(pid int) bool {
+	return unix.Kill(pid, 0) != unix.ESRCH
+}
+
+// processName 读取进程名，用于冲突提示
+func processName(pid int) string {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// lockedError 构造注明占用进程的冲突错误
+func lockedError(dev string, pid int) error {
+	return fmt.Errorf("%w: %s locked by pid %d (%s)", ErrPortLocked, dev, pid, processName(pid))
+}
+
+// busyError 把打开时的 EBUSY（他人已设置 TIOCEXCL）转换为 ErrPortLocked，
+// 能在 /proc 中找到打开该设备的进程时注明它
+func busyError(dev string, err error) error {
+	if pid := deviceHolder(dev); pid > 0 {
+		return lockedError(dev, pid)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrPortLocked, dev, err)
+}
+
+// deviceHolder 扫描 /proc/*/fd 查找打开了该设备的其他进程，找不到时返回 0
+func deviceHolder(dev string) int {
+	if real, err := filepath.EvalSymlinks(dev); err == nil {
+		dev = real
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	self := os.Getpid()
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && target == dev {
+				return pid
+			}
+		}
+	}
+	return 0
+}
+
+// release 删除本进程持有的锁文件；锁已被他人接管时保留
+func (l *deviceLock) release() {
+	if l == nil {
+		return
+	}
+	heldMu.Lock()
+	delete(heldLocks, l.path)
+	heldMu.Unlock()
+	if pid, ok := readLockPID(l.path); ok && pid != os.Getpid() {
+		return
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("⚠️ remove lock %s failed: %v\n", l.path, err)
+	}
+}
@@ -0,0 +1,95 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// useLockDir 把锁文件目录指向临时目录，测试结束时恢复
+func useLockDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig := lockDir
+	lockDir = dir
+	t.Cleanup(func() { lockDir = orig })
+	return dir
+}
+
+// writeLock 以 HDB UUCP 格式写入锁文件
+func writeLock(t *testing.T, dir, dev, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, lockName(dev))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// exitedPID 返回一个已退出进程的 PID
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run helper process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestLockDeviceRemovesStaleLock(t *testing.T) {
+	dir := useLockDir(t)
+	path := writeLock(t, dir, "/dev/ttyTEST0", fmt.Sprintf("%10d\n", exitedPID(t)))
+
+	l, err := lockDevice("/dev/ttyTEST0", "P")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.release()
+	if pid, ok := readLockPID(path); !ok || pid != os.Getpid() {
+		t.Fatalf("lock pid = %d, want %d", pid, os.Getpid())
+	}
+}
+
+func TestLockDeviceKeepsLiveAndUnreadableLocks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+	}{
+		{"live pid", fmt.Sprintf("%10d\n", os.Getppid())},
+		{"garbage", "not a pid\n"},
+		{"empty", ""},
+		{"zero pid", fmt.Sprintf("%10d\n", 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := useLockDir(t)
+			path := writeLock(t, dir, "/dev/ttyTEST0", tc.content)
+
+			_, err := lockDevice("/dev/ttyTEST0", "P")
+			if !errors.Is(err, ErrPortLocked) {
+				t.Fatalf("lockDevice error = %v, want ErrPortLocked", err)
+			}
+			data, rerr := os.ReadFile(path)
+			if rerr != nil || string(data) != tc.content {
+				t.Fatalf("lock file changed to %q (%v), want %q", data, rerr, tc.content)
+			}
+		})
+	}
+}
+
+func TestLockDeviceReusesOwnLock(t *testing.T) {
+	dir := useLockDir(t)
+	writeLock(t, dir, "/dev/ttyTEST0", fmt.Sprintf("%10d\n", os.Getpid()))
+
+	l, err := lockDevice("/dev/ttyTEST0", "P")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.release()
+	// 同一进程的另一个端口不能再打开同一设备
+	if _, err := lockDevice("/dev/ttyTEST0", "Q"); !errors.Is(err, ErrPortLocked) {
+		t.Fatalf("second lockDevice error = %v, want ErrPortLocked", err)
+	}
+}
@@ -0,0 +1,212 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModemLine 是调制解调器控制/状态线的位掩码，取值与 Linux TIOCM_* 一致
+type ModemLine int
+
+const (
+	LineDTR ModemLine = 0x002 // 输出：数据终端就绪
+	LineRTS ModemLine = 0x004 // 输出：请求发送
+	LineCTS ModemLine = 0x020 // 输入：允许发送
+	LineDCD ModemLine = 0x040 // 输入：载波检测
+	LineRI  ModemLine = 0x080 // 输入：振铃指示
+	LineDSR ModemLine = 0x100 // 输入：数据设备就绪
+
+	// OutputLines 是可以由本端设置的线
+	OutputLines = LineDTR | LineRTS
+	// InputLines 是由对端驱动、可以监视变化的线
+	InputLines = LineCTS | LineDCD | LineRI | LineDSR
+)
+
+// ErrModemUnsupported 表示驱动不支持某项调制解调器线操作
+var ErrModemUnsupported = errors.New("modem control not supported")
+
+var modemLineNames = []struct {
+	line ModemLine
+	name string
+}{
+	{LineDTR, "dtr"}, {LineRTS, "rts"}, {LineCTS, "cts"},
+	{LineDSR, "dsr"}, {LineDCD, "dcd"}, {LineRI, "ri"},
+}
+
+// ParseModemLine 把 dtr/rts/cts/dsr/dcd/ri 解析为对应的位
+func ParseModemLine(name string) (ModemLine, error) {
+	for _, l := range modemLineNames {
+		if strings.EqualFold(name, l.name) {
+			return l.line, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown modem line %q", name)
+}
+
+// Map 把各线状态展开为 名称→电平，便于序列化上报
+func (m ModemLine) Map() map[string]bool {
+	out := make(map[string]bool, len(modemLineNames))
+	for _, l := range modemLineNames {
+		out[l.name] = m&l.line != 0
+	}
+	return out
+}
+
+// String 以 DTR|RTS 形式列出有效的线
+func (m ModemLine) String() string {
+	var names []string
+	for _, l := range modemLineNames {
+		if m&l.line != 0 {
+			names = append(names, strings.ToUpper(l.name))
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, "|")
+}
+
+// ModemController 由支持调制解调器控制线的端口实现
+type ModemController interface {
+	// ModemLines 读取当前所有控制/状态线
+	ModemLines() (ModemLine, error)
+	// SetModemLines 把 mask 中的输出线（DTR/RTS）置为 on
+	SetModemLines(mask ModemLine, on bool) error
+	// WaitModemChange 阻塞直到 mask 中任一输入线发生变化（TIOCMIWAIT），
+	// 驱动不支持时返回 ErrModemUnsupported
+	WaitModemChange(mask ModemLine) error
+}
+
+// PulseModemLine 把输出线置为 on，保持 d 后恢复为脉冲前的电平
+func PulseModemLine(mc ModemController, mask ModemLine, on bool, d time.Duration) error {
+	prev, err := mc.ModemLines()
+	if err != nil {
+		return err
+	}
+	if err := mc.SetModemLines(mask, on); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	// mask 中各线脉冲前的电平可能不同，分别恢复
+	if high := mask & prev; high != 0 {
+		if err := mc.SetModemLines(high, true); err != nil {
+			return err
+		}
+	}
+	if low := mask &^ prev; low != 0 {
+		if err := mc.SetModemLines(low, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchModem 在后台监视输入线，每次变化回调 onChange(旧状态, 新状态)。
+// 优先使用 TIOCMIWAIT 等待变化，驱动不支持时退化为按 poll 间隔轮询。
+// stop 关闭后退出；由于 TIOCMIWAIT 无法被取消，阻塞中的等待会在下一次变化或端口关闭出错时才返回，
+// 此时端口可能已被重新打开并由新的监视接管，因此每次唤醒和读取后都重新检查 stop，旧监视直接退出，
+// 不会把新句柄上的状态当作自己的变化上报
+func WatchModem(mc ModemController, poll time.Duration, stop <-chan struct{}, onChange func(old, cur ModemLine)) {
+	go func() {
+		last, err := mc.ModemLines()
+		if err != nil {
+			fmt.Printf("⚠️ read modem lines failed: %v\n", err)
+			return
+		}
+		useWait := true
+		for {
+			if stopped(stop) {
+				return
+			}
+			if useWait {
+				err := mc.WaitModemChange(InputLines)
+				if stopped(stop) {
+					// 等待期间端口已关闭，唤醒可能来自关闭出错或重新打开后的变化
+					return
+				}
+				if err != nil {
+					if !errors.Is(err, ErrModemUnsupported) {
+						fmt.Printf("⚠️ wait modem change failed: %v\n", err)
+						return
+					}
+					useWait = false
+				}
+			}
+			if !useWait {
+				select {
+				case <-stop:
+					return
+				case <-time.After(poll):
+				}
+			}
+			cur, err := mc.ModemLines()
+			if stopped(stop) {
+				return
+			}
+			if err != nil {
+				fmt.Printf("⚠️ read modem lines failed: %v\n", err)
+				return
+			}
+			if cur != last {
+				onChange(last, cur)
+				last = cur
+			}
+		}
+	}()
+}
+
+// stopped 判断 stop 是否已关闭
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// ModemHub 把端口唯一的 WatchModem 监视到的输入线变化分发给多个订阅者
+// （MQTT 上报、RFC 2217 会话），使用方不再各自启动监视协程。
+// nil 表示端口不支持调制解调器线，订阅不会收到任何变化。
+type ModemHub struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]func(old, cur ModemLine)
+}
+
+// NewModemHub 创建空的分发器
+func NewModemHub() *ModemHub {
+	return &ModemHub{subs: make(map[int]func(old, cur ModemLine))}
+}
+
+// Subscribe 注册变化回调，返回取消函数；取消返回后回调不会再被调用
+func (h *ModemHub) Subscribe(fn func(old, cur ModemLine)) func() {
+	if h == nil {
+		return func() {}
+	}
+	h.mu.Lock()
+	id := h.next
+	h.next++
+	h.subs[id] = fn
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		delete(h.subs, id)
+		h.mu.Unlock()
+	}
+}
+
+// Publish 把一次变化同步分发给所有订阅者；回调中不能订阅或取消
+func (h *ModemHub) Publish(old, cur ModemLine) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, fn := range h.subs {
+		fn(old, cur)
+	}
+}
@@ -0,0 +1,50 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// getModemLines 通过 TIOCMGET 读取所有调制解调器线
+func getModemLines(fd uintptr) (ModemLine, error) {
+	v, err := unix.IoctlGetInt(int(fd), unix.TIOCMGET)
+	if err != nil {
+		return 0, fmt.Errorf("TIOCMGET: %w", err)
+	}
+	return ModemLine(v), nil
+}
+
+// setModemLines 通过 TIOCMBIS/TIOCMBIC 设置或清除输出线
+func setModemLines(fd uintptr, mask ModemLine, on bool) error {
+	if mask&^OutputLines != 0 {
+		return fmt.Errorf("modem lines %s are not outputs", mask&^OutputLines)
+	}
+	req := uint(unix.TIOCMBIC)
+	if on {
+		req = unix.TIOCMBIS
+	}
+	if err := unix.IoctlSetPointerInt(int(fd), req, int(mask)); err != nil {
+		return fmt.Errorf("set modem lines %s: %w", mask, err)
+	}
+	return nil
+}
+
+// waitModemChange 通过 TIOCMIWAIT 等待 mask 中任一线变化；等待无法取消，
+// 端口关闭后可能在重新打开之后才返回，调用方需在返回后确认监视是否仍然有效（见 WatchModem）
+func waitModemChange(fd uintptr, mask ModemLine) error {
+	for {
+		err := unix.IoctlSetInt(int(fd), unix.TIOCMIWAIT, int(mask))
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.ENOTTY), errors.Is(err, unix.EINVAL):
+			return ErrModemUnsupported
+		default:
+			return fmt.Errorf("TIOCMIWAIT: %w", err)
+		}
+	}
+}
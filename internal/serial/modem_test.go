@@ -0,0 +1,75 @@
+package serial
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingModem 模拟 TIOCMIWAIT：WaitModemChange 阻塞到 wake 被写入，无法被取消
+type blockingModem struct {
+	mu      sync.Mutex
+	lines   ModemLine
+	waiting chan struct{} // 每次进入等待时写入
+	wake    chan struct{}
+}
+
+func (m *blockingModem) ModemLines() (ModemLine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lines, nil
+}
+
+func (m *blockingModem) SetModemLines(mask ModemLine, on bool) error { return nil }
+
+func (m *blockingModem) WaitModemChange(mask ModemLine) error {
+	m.waiting <- struct{}{}
+	<-m.wake
+	return nil
+}
+
+func (m *blockingModem) set(lines ModemLine) {
+	m.mu.Lock()
+	m.lines = lines
+	m.mu.Unlock()
+}
+
+func TestWatchModemReportsChange(t *testing.T) {
+	m := &blockingModem{waiting: make(chan struct{}, 1), wake: make(chan struct{})}
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := make(chan [2]ModemLine, 1)
+	WatchModem(m, time.Hour, stop, func(old, cur ModemLine) { changes <- [2]ModemLine{old, cur} })
+
+	<-m.waiting
+	m.set(LineCTS)
+	m.wake <- struct{}{}
+	select {
+	case c := <-changes:
+		if c != [2]ModemLine{0, LineCTS} {
+			t.Fatalf("change = %v → %v, want none → CTS", c[0], c[1])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not reported")
+	}
+}
+
+func TestWatchModemIgnoresStaleWakeup(t *testing.T) {
+	m := &blockingModem{waiting: make(chan struct{}, 1), wake: make(chan struct{})}
+	stop := make(chan struct{})
+	changes := make(chan [2]ModemLine, 1)
+	WatchModem(m, time.Hour, stop, func(old, cur ModemLine) { changes <- [2]ModemLine{old, cur} })
+
+	// 等待阻塞期间端口断开、重新打开，新句柄上的线路状态与旧监视无关
+	<-m.waiting
+	close(stop)
+	m.set(LineDCD)
+	m.wake <- struct{}{}
+	select {
+	case c := <-changes:
+		t.Fatalf("stale watcher reported %v → %v after stop", c[0], c[1])
+	case <-m.waiting:
+		t.Fatal("stale watcher waited again after stop")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
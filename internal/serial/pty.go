@@ -0,0 +1,187 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// PeerPather 由向其他进程暴露设备路径的端口实现（如 pty 的从端），
+// 连接状态事件中会附带该路径
+type PeerPather interface {
+	PeerPath() string
+}
+
+// PTYPort 是基于伪终端的虚拟串口：服务持有主端，另一进程打开从端即可收发，
+// 用于无硬件时的本地联调和 CI 中的 MQTT↔串口端到端测试。
+// 配置了 device 时在该路径创建指向从端的符号链接，便于对端使用固定路径。
+type PTYPort struct {
+	name   string     // 逻辑名称，构造后不变
+	device string     // 符号链接路径，构造后不变
+	lmu    sync.Mutex // 保护以下字段：监管器重新打开时替换句柄，读写与状态查询并发取快照，线路参数可能被并发修改
+	cfg    config.Port
+	master *os.File
+	slave  *os.File // 服务自身持有一个从端句柄，对端反复打开/关闭时主端不会因挂断返回 EIO
+	path   string   // 从端路径，如 /dev/pts/3
+	link   string   // 已创建的符号链接
+}
+
+// NewPTYPort 根据配置返回 PTYPort 实例
+func NewPTYPort(cfg config.Port) Port {
+	return &PTYPort{name: cfg.Name, device: cfg.Device, cfg: cfg}
+}
+
+// Open 分配伪终端对，从端设为原始模式
+func (t *PTYPort) Open() error {
+	master, slave, path, err := openPTY()
+	if err != nil {
+		return fmt.Errorf("open pty: %w", err)
+	}
+	t.lmu.Lock()
+	t.master, t.slave, t.path = master, slave, path
+	t.lmu.Unlock()
+	if t.device != "" {
+		if err := replaceSymlink(path, t.device); err != nil {
+			t.Close()
+			return fmt.Errorf("link %s → %s: %w", t.device, path, err)
+		}
+		t.lmu.Lock()
+		t.link = t.device
+		t.lmu.Unlock()
+	}
+	fmt.Printf("🧪 [%s] pty slave %s%s\n", t.name, path, linkSuffix(t.device))
+	return nil
+}
+
+// linkSuffix 返回日志中符号链接部分
+func linkSuffix(link string) string {
+	if link == "" {
+		return ""
+	}
+	return " (linked at " + link + ")"
+}
+
+// replaceSymlink 在 link 处创建指向 target 的符号链接，只覆盖已有的符号链接
+func replaceSymlink(target, link string) error {
+	if fi, err := os.Lstat(link); err == nil {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s exists and is not a symlink", link)
+		}
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, link)
+}
+
+// Close 关闭主从两端并删除符号链接
+func (t *PTYPort) Close() error {
+	t.lmu.Lock()
+	master, slave, link := t.master, t.slave, t.link
+	t.master, t.slave, t.link = nil, nil, ""
+	t.lmu.Unlock()
+
+	var firstErr error
+	if link != "" {
+		os.Remove(link)
+	}
+	if slave != nil {
+		slave.Close()
+	}
+	if master != nil {
+		firstErr = master.Close()
+	}
+	return firstErr
+}
+
+// handle 返回当前主端句柄，供读循环与各写入路径并发访问；已关闭时返回 nil
+func (t *PTYPort) handle() *os.File {
+	t.lmu.Lock()
+	defer t.lmu.Unlock()
+	return t.master
+}
+
+// Read 从主端读取对端写入的数据
+func (t *PTYPort) Read(p []byte) (int, error) {
+	master := t.handle()
+	if master == nil {
+		return 0, os.ErrClosed
+	}
+	return master.Read(p)
+}
+
+// Write 向主端写入，对端从从端读到
+func (t *PTYPort) Write(p []byte) (int, error) {
+	master := t.handle()
+	if master == nil {
+		return 0, fmt.Errorf("pty write failed: %w", os.ErrClosed)
+	}
+	n, err := master.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("pty write failed: %w", err)
+	}
+	return n, nil
+}
+
+// Name 返回逻辑名称
+func (t *PTYPort) Name() string {
+	return t.name
+}
+
+// ReadFrame 与 UART 一样把一次 Read 当作一帧
+func (t *PTYPort) ReadFrame() ([]byte, error) {
+	buf := make([]byte, 4096)
+	n, err := t.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// WriteFrame 写整帧
+func (t *PTYPort) WriteFrame(frame []byte) error {
+	_, err := t.Write(frame)
+	return err
+}
+
+// PeerPath 返回对端应打开的路径：有符号链接时返回链接，否则返回 /dev/pts/N
+func (t *PTYPort) PeerPath() string {
+	t.lmu.Lock()
+	defer t.lmu.Unlock()
+	if t.link != "" {
+		return t.link
+	}
+	return t.path
+}
+
+// Probe 检查伪终端是否仍然可用
+func (t *PTYPort) Probe() error {
+	t.lmu.Lock()
+	defer t.lmu.Unlock()
+	return probeTTY(t.slave.Fd())
+}
+
+// LineSettings 返回当前线路参数
+func (t *PTYPort) LineSettings() LineSettings {
+	t.lmu.Lock()
+	defer t.lmu.Unlock()
+	return lineSettingsOf(t.cfg)
+}
+
+// SetLineSettings 把线路参数写入从端 termios；伪终端不按波特率限速，
+// 对端可以读取到新参数，便于联调 RFC 2217 等远程配置路径
+func (t *PTYPort) SetLineSettings(ls LineSettings) error {
+	t.lmu.Lock()
+	defer t.lmu.Unlock()
+	if t.slave == nil {
+		return os.ErrClosed
+	}
+	cfg, err := reconfigure(t.slave, t.cfg, ls)
+	if err != nil {
+		return err
+	}
+	t.cfg = cfg
+	return nil
+}
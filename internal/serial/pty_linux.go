@@ -0,0 +1,61 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY 通过 /dev/ptmx 分配伪终端：解锁从端、打开从端并设为原始模式。
+// 主端保持非阻塞由 runtime poller 管理，Close 可打断阻塞中的 Read。
+func openPTY() (master, slave *os.File, path string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	rc, err := master.SyscallConn()
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	var ioErr error
+	err = rc.Control(func(fd uintptr) {
+		if ioErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioErr != nil {
+			ioErr = fmt.Errorf("TIOCSPTLCK: %w", ioErr)
+			return
+		}
+		var n uint32
+		if n, ioErr = unix.IoctlGetUint32(int(fd), unix.TIOCGPTN); ioErr != nil {
+			ioErr = fmt.Errorf("TIOCGPTN: %w", ioErr)
+			return
+		}
+		path = fmt.Sprintf("/dev/pts/%d", n)
+	})
+	if err == nil {
+		err = ioErr
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+
+	slave, err = os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, "", err
+	}
+	// 关闭回显和行规程，字节原样透传
+	t, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err == nil {
+		makeRaw(t)
+		t.Cflag = t.Cflag&^unix.CSIZE | unix.CS8
+		err = unix.IoctlSetTermios(int(slave.Fd()), unix.TCSETS, t)
+	}
+	if err != nil {
+		slave.Close()
+		master.Close()
+		return nil, nil, "", fmt.Errorf("set pty raw: %w", err)
+	}
+	return master, slave, path, nil
+}
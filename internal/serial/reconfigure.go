@@ -0,0 +1,137 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// LineSettings 是可以在运行时修改的线路参数
+type LineSettings struct {
+	Baudrate    int     `json:"baudrate"`
+	DataBits    int     `json:"dataBits"`
+	Parity      string  `json:"parity"`
+	StopBits    float64 `json:"stopBits"`
+	FlowControl string  `json:"flowControl"`
+	// 读取聚合（仅 termios 后端），修改后对下一次 Read 生效
+	InterCharTimeoutMs int `json:"interCharTimeoutMs,omitempty"`
+	MinRead            int `json:"minRead,omitempty"`
+}
+
+// LineConfigurer 由可以在运行时修改线路参数的端口实现
+type LineConfigurer interface {
+	// LineSettings 返回当前线路参数
+	LineSettings() LineSettings
+	// SetLineSettings 校验并立即应用新的线路参数，之后的重新打开也沿用新参数
+	SetLineSettings(ls LineSettings) error
+}
+
+// ErrLineFixed 表示端口不支持在运行时修改线路参数
+var ErrLineFixed = errors.New("port cannot change line settings")
+
+// PortAccess 是端口的独占访问路径：写入与线路参数修改都与端口的其他写入、事务互斥。
+// 驱动注入带端口写入锁的实现，共享服务器与自动检测经它访问端口
+type PortAccess interface {
+	io.Writer
+	// UpdateLine 在独占期间把当前参数交给 update，update 返回新参数及是否应用；返回最终生效的参数
+	UpdateLine(update func(cur LineSettings) (LineSettings, bool)) (LineSettings, error)
+}
+
+// ApplyLineUpdate 读取端口当前参数并按 update 的结果修改，调用方负责与其他访问互斥
+func ApplyLineUpdate(p Port, update func(cur LineSettings) (LineSettings, bool)) (LineSettings, error) {
+	lc, ok := p.(LineConfigurer)
+	if !ok {
+		return LineSettings{}, fmt.Errorf("%w: %s", ErrLineFixed, p.Name())
+	}
+	cur := lc.LineSettings()
+	next, apply := update(cur)
+	if !apply {
+		return cur, nil
+	}
+	if err := lc.SetLineSettings(next); err != nil {
+		return cur, err
+	}
+	return lc.LineSettings(), nil
+}
+
+// supervisorAccess 直接访问监管器，用于未注入驱动写入路径时（不与其他写入互斥）
+type supervisorAccess struct {
+	sup *Supervisor
+}
+
+// Write 实现 io.Writer
+func (a supervisorAccess) Write(p []byte) (int, error) {
+	return a.sup.Write(p)
+}
+
+// UpdateLine 实现 PortAccess
+func (a supervisorAccess) UpdateLine(update func(cur LineSettings) (LineSettings, bool)) (LineSettings, error) {
+	return ApplyLineUpdate(a.sup.Port(), update)
+}
+
+// lineSettingsOf 取出配置中的线路参数
+func lineSettingsOf(cfg config.Port) LineSettings {
+	return LineSettings{
+		Baudrate:    cfg.Baudrate,
+		DataBits:    cfg.DataBits,
+		Parity:      cfg.Parity,
+		StopBits:    cfg.StopBits,
+		FlowControl: cfg.FlowControl,
+
+		InterCharTimeoutMs: cfg.InterCharTimeoutMs,
+		MinRead:            cfg.MinRead,
+	}
+}
+
+// withLineSettings 返回替换了线路参数并通过校验的配置副本
+func withLineSettings(cfg config.Port, ls LineSettings) (config.Port, error) {
+	cfg.Baudrate = ls.Baudrate
+	cfg.DataBits = ls.DataBits
+	cfg.Parity = ls.Parity
+	cfg.StopBits = ls.StopBits
+	cfg.FlowControl = ls.FlowControl
+	cfg.InterCharTimeoutMs = ls.InterCharTimeoutMs
+	cfg.MinRead = ls.MinRead
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// readAggregator 由可以在运行时修改读取聚合方式的底层句柄实现
+type readAggregator interface {
+	setReadAggregation(vmin int, interChar time.Duration)
+}
+
+// setReadAggregation 转发给被包装的句柄
+func (l *lockedPort) setReadAggregation(vmin int, interChar time.Duration) {
+	if ra, ok := l.rawPort.(readAggregator); ok {
+		ra.setReadAggregation(vmin, interChar)
+	}
+}
+
+// reconfigure 在已打开的 tty 上应用新的线路参数，成功后返回新配置；
+// 只修改 termios 和读取聚合，不重新打开设备，读循环的解析缓存与各类订阅不受影响；
+// 端口尚未打开时返回 os.ErrClosed
+func reconfigure(h rawPort, cfg config.Port, ls LineSettings) (config.Port, error) {
+	if h == nil {
+		return cfg, os.ErrClosed
+	}
+	next, err := withLineSettings(cfg, ls)
+	if err != nil {
+		return cfg, err
+	}
+	if err := setLine(int(h.Fd()), next); err != nil {
+		return cfg, err
+	}
+	if ra, ok := h.(readAggregator); ok {
+		ra.setReadAggregation(readAggregation(next))
+	}
+	fmt.Printf("🔧 [%s] line settings %d %d/%s/%v %s interChar=%dms minRead=%d\n", cfg.Name,
+		ls.Baudrate, ls.DataBits, ls.Parity, ls.StopBits, ls.FlowControl, ls.InterCharTimeoutMs, ls.MinRead)
+	return next, nil
+}
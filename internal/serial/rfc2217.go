@@ -0,0 +1,249 @@
+package serial
+
+import "github.com/linjuya-lu/device_uart_go/internal/config"
+
+// Telnet 命令与选项（RFC 854/856/858）
+const (
+	tnSE   = 240
+	tnSB   = 250
+	tnWILL = 251
+	tnWONT = 252
+	tnDO   = 253
+	tnDONT = 254
+	tnIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44 // RFC 2217 COM-PORT-OPTION
+)
+
+// COM-PORT-OPTION 子命令；服务器的应答/通知为对应值 + cpcServer
+const (
+	cpcSignature         = 0
+	cpcSetBaudrate       = 1
+	cpcSetDataSize       = 2
+	cpcSetParity         = 3
+	cpcSetStopSize       = 4
+	cpcSetControl        = 5
+	cpcNotifyLineState   = 6
+	cpcNotifyModemState  = 7
+	cpcFlowSuspend       = 8
+	cpcFlowResume        = 9
+	cpcSetLineStateMask  = 10
+	cpcSetModemStateMask = 11
+	cpcPurgeData         = 12
+
+	cpcServer = 100
+)
+
+// SET-CONTROL 取值
+const (
+	ctlFlowRequest  = 0
+	ctlFlowNone     = 1
+	ctlFlowXONXOFF  = 2
+	ctlFlowHardware = 3
+	ctlBreakRequest = 4
+	ctlBreakOn      = 5
+	ctlBreakOff     = 6
+	ctlDTRRequest   = 7
+	ctlDTROn        = 8
+	ctlDTROff       = 9
+	ctlRTSRequest   = 10
+	ctlRTSOn        = 11
+	ctlRTSOff       = 12
+)
+
+// NOTIFY-LINESTATE 位
+const (
+	lsOverrun = 0x02
+	lsParity  = 0x04
+	lsFraming = 0x08
+	lsBreak   = 0x10
+
+	// lsErrors 是请求服务器上报的线路状态（接收错误与 BREAK）
+	lsErrors = lsOverrun | lsParity | lsFraming | lsBreak
+)
+
+// NOTIFY-MODEMSTATE 位（高 4 位为当前电平，低 4 位为变化标志）
+const (
+	msCTS = 0x10
+	msDSR = 0x20
+	msRI  = 0x40
+	msCD  = 0x80
+)
+
+var (
+	rfc2217Parity = map[string]byte{
+		config.ParityNone: 1, config.ParityOdd: 2, config.ParityEven: 3,
+		config.ParityMark: 4, config.ParitySpace: 5,
+	}
+	rfc2217Stop = map[float64]byte{1: 1, 2: 2, 1.5: 3}
+	rfc2217Flow = map[string]byte{
+		config.FlowNone: ctlFlowNone, config.FlowXONXOFF: ctlFlowXONXOFF, config.FlowRTSCTS: ctlFlowHardware,
+	}
+	rfc2217Modem = []struct {
+		bit  byte
+		line ModemLine
+	}{
+		{msCTS, LineCTS}, {msDSR, LineDSR}, {msRI, LineRI}, {msCD, LineDCD},
+	}
+)
+
+// reverseLookup 在编码表中按取值反查配置值
+func reverseLookup[K comparable](m map[K]byte, v byte) (K, bool) {
+	for k, b := range m {
+		if b == v {
+			return k, true
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+// modemFromRFC2217 把 NOTIFY-MODEMSTATE 的电平位转换为 ModemLine
+func modemFromRFC2217(b byte) ModemLine {
+	var m ModemLine
+	for _, l := range rfc2217Modem {
+		if b&l.bit != 0 {
+			m |= l.line
+		}
+	}
+	return m
+}
+
+// modemToRFC2217 把输入线状态编码为 NOTIFY-MODEMSTATE，changed 中的线置变化标志
+func modemToRFC2217(cur, changed ModemLine) byte {
+	var b byte
+	for _, l := range rfc2217Modem {
+		if cur&l.line != 0 {
+			b |= l.bit
+		}
+		if changed&l.line != 0 {
+			b |= l.bit >> 4
+		}
+	}
+	return b
+}
+
+// Telnet 解码状态
+const (
+	tnStateData = iota
+	tnStateIAC
+	tnStateVerb
+	tnStateSub
+	tnStateSubIAC
+)
+
+// maxSubneg 限制子协商长度，防止异常对端无限制占用内存
+const maxSubneg = 256
+
+// telnetCodec 把 Telnet 字节流拆分为数据与命令：
+// 数据中的 IAC IAC 还原为 0xFF，选项协商与子协商通过回调交给上层。
+// onSub 收到的 data 在回调返回后会被复用，需要保留时应复制。
+type telnetCodec struct {
+	state    int
+	verb     byte
+	sb       []byte
+	onOption func(verb, opt byte)
+	onSub    func(opt byte, data []byte)
+}
+
+// decode 把 src 中的数据字节写入 dst 并返回字节数；dst 可以与 src 相同（原地解码）
+func (c *telnetCodec) decode(dst, src []byte) int {
+	n := 0
+	for _, b := range src {
+		switch c.state {
+		case tnStateData:
+			if b == tnIAC {
+				c.state = tnStateIAC
+				continue
+			}
+			dst[n] = b
+			n++
+		case tnStateIAC:
+			switch b {
+			case tnIAC:
+				dst[n] = tnIAC
+				n++
+				c.state = tnStateData
+			case tnWILL, tnWONT, tnDO, tnDONT:
+				c.verb = b
+				c.state = tnStateVerb
+			case tnSB:
+				c.sb = c.sb[:0]
+				c.state = tnStateSub
+			default:
+				// NOP、AYT 等其他命令忽略
+				c.state = tnStateData
+			}
+		case tnStateVerb:
+			c.state = tnStateData
+			if c.onOption != nil {
+				c.onOption(c.verb, b)
+			}
+		case tnStateSub:
+			if b == tnIAC {
+				c.state = tnStateSubIAC
+				continue
+			}
+			if len(c.sb) < maxSubneg {
+				c.sb = append(c.sb, b)
+			}
+		case tnStateSubIAC:
+			switch b {
+			case tnSE:
+				c.state = tnStateData
+				if len(c.sb) > 0 && c.onSub != nil {
+					c.onSub(c.sb[0], c.sb[1:])
+				}
+			case tnIAC:
+				if len(c.sb) < maxSubneg {
+					c.sb = append(c.sb, tnIAC)
+				}
+				c.state = tnStateSub
+			default:
+				// 畸形子协商，丢弃
+				c.state = tnStateData
+			}
+		}
+	}
+	return n
+}
+
+// escapeIAC 把数据中的 0xFF 转义为 IAC IAC；没有 0xFF 时直接返回原切片
+func escapeIAC(p []byte) []byte {
+	count := 0
+	for _, b := range p {
+		if b == tnIAC {
+			count++
+		}
+	}
+	if count == 0 {
+		return p
+	}
+	out := make([]byte, 0, len(p)+count)
+	for _, b := range p {
+		out = append(out, b)
+		if b == tnIAC {
+			out = append(out, tnIAC)
+		}
+	}
+	return out
+}
+
+// telnetOption 构造 IAC verb opt
+func telnetOption(verb, opt byte) []byte {
+	return []byte{tnIAC, verb, opt}
+}
+
+// comPortCmd 构造 IAC SB COM-PORT-OPTION cmd value... IAC SE
+func comPortCmd(cmd byte, value ...byte) []byte {
+	out := []byte{tnIAC, tnSB, optComPort, cmd}
+	out = append(out, escapeIAC(value)...)
+	return append(out, tnIAC, tnSE)
+}
+
+// be32 以大端序编码波特率
+func be32(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
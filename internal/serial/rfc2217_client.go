@@ -0,0 +1,499 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// rfc2217Timeout 是选项协商和等待线路参数确认的超时
+const rfc2217Timeout = 5 * time.Second
+
+// defaultBreak 是 SendBreak(0) 时的 BREAK 时长
+const defaultBreak = 250 * time.Millisecond
+
+// RFC2217Port 是 RFC 2217（Telnet COM Port Control）客户端：
+// 连接后协商 COM-PORT-OPTION，把本端配置的波特率/数据位/校验/停止位/流控下发给串口服务器，
+// 数据双向做 IAC 转义；服务器上报的线路状态计入 Counters（BREAK 按 detectBreak 以 ErrBreak 返回），
+// 调制解调器状态通过 ModemController 提供，DTR/RTS 通过 SET-CONTROL 远程设置，
+// 运行时修改线路参数时重新下发并等待服务器确认（LineConfigurer）。
+type RFC2217Port struct {
+	name   string     // 逻辑名称，构造后不变
+	wmu    sync.Mutex // 数据与控制命令共用连接，串行化写入
+	lmu    sync.Mutex // 串行化线路参数修改，一次只等待一组确认
+	closed atomic.Bool
+
+	mu       sync.Mutex
+	cfg      config.Port     // 端口配置，线路参数被服务器确认后替换
+	conn     *rfc2217Conn    // 当前连接，重新打开时替换；使用前在锁内取快照
+	comPort  int             // 服务器对 COM-PORT-OPTION 的应答：0 未应答，1 接受，-1 拒绝
+	acks     map[byte][]byte // 子命令 → 服务器确认的取值
+	ackCh    chan struct{}   // 收到线路参数确认时关闭并替换
+	modem    byte            // 最近一次 NOTIFY-MODEMSTATE
+	outputs  ModemLine       // 服务器报告或本端设置的 DTR/RTS
+	modemCh  chan struct{}   // 调制解调器状态变化时关闭并替换
+	done     chan struct{}   // Close 时关闭，唤醒 WaitModemChange
+	counters Counters
+	brk      bool // 有待返回的 BREAK
+}
+
+// rfc2217Conn 是一次连接的状态：解码器与协商期间收到的数据只由该连接的读取方使用
+type rfc2217Conn struct {
+	net.Conn
+	codec   telnetCodec
+	pending []byte // 协商期间收到的串口数据
+}
+
+// NewRFC2217Port 根据配置返回 RFC2217Port 实例
+func NewRFC2217Port(cfg config.Port) Port {
+	return &RFC2217Port{name: cfg.Name, cfg: cfg}
+}
+
+// Open 连接串口服务器，协商 COM-PORT-OPTION 并下发线路参数，等待服务器逐项确认
+func (r *RFC2217Port) Open() error {
+	// 重新连接时沿用运行时修改过的线路参数
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	nc, err := dialRemote(cfg.Address)
+	if err != nil {
+		return err
+	}
+	c := &rfc2217Conn{Conn: nc, codec: telnetCodec{onOption: r.onOption, onSub: r.onSub}}
+	r.mu.Lock()
+	r.conn = c
+	r.comPort = 0
+	r.acks = make(map[byte][]byte)
+	r.ackCh = make(chan struct{})
+	r.modem = 0
+	r.outputs = 0
+	r.modemCh = make(chan struct{})
+	r.done = make(chan struct{})
+	r.brk = false
+	r.mu.Unlock()
+	r.closed.Store(false)
+
+	if err := r.handshake(c, cfg); err != nil {
+		r.Close()
+		return fmt.Errorf("rfc2217 %s: %w", cfg.Address, err)
+	}
+	fmt.Printf("🌐 [%s] rfc2217 connected to %s, %d baud\n", r.name, cfg.Address, cfg.Baudrate)
+	return nil
+}
+
+// handshake 完成选项协商与线路参数设置
+func (r *RFC2217Port) handshake(c *rfc2217Conn, cfg config.Port) error {
+	var neg []byte
+	neg = append(neg, telnetOption(tnWILL, optBinary)...)
+	neg = append(neg, telnetOption(tnDO, optBinary)...)
+	neg = append(neg, telnetOption(tnWILL, optSGA)...)
+	neg = append(neg, telnetOption(tnDO, optSGA)...)
+	neg = append(neg, telnetOption(tnWILL, optComPort)...)
+	if err := r.send(neg); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(rfc2217Timeout)
+	if err := r.waitFor(c, deadline, func() bool { return r.comPort != 0 }); err != nil {
+		return fmt.Errorf("negotiate COM-PORT-OPTION: %w", err)
+	}
+	if r.comPort < 0 {
+		return fmt.Errorf("server refused COM-PORT-OPTION")
+	}
+
+	want, cmds := lineRequest(cfg)
+	cmds = append(cmds, comPortCmd(cpcSetLineStateMask, lsErrors)...)
+	cmds = append(cmds, comPortCmd(cpcSetModemStateMask, 0xFF)...)
+	cmds = append(cmds, comPortCmd(cpcSetControl, ctlDTRRequest)...)
+	cmds = append(cmds, comPortCmd(cpcSetControl, ctlRTSRequest)...)
+	if err := r.send(cmds); err != nil {
+		return err
+	}
+	err := r.waitFor(c, deadline, func() bool { return len(r.acks) >= len(want) })
+	if err != nil {
+		return fmt.Errorf("wait for line settings ack: %w", err)
+	}
+	r.mu.Lock()
+	err = r.checkAcks(want)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.SetReadDeadline(time.Time{})
+}
+
+// lineRequest 返回下发 cfg 线路参数的子命令取值与编码后的命令
+func lineRequest(cfg config.Port) (map[byte][]byte, []byte) {
+	want := map[byte][]byte{
+		cpcSetBaudrate: be32(uint32(cfg.Baudrate)),
+		cpcSetDataSize: {byte(cfg.DataBits)},
+		cpcSetParity:   {rfc2217Parity[cfg.Parity]},
+		cpcSetStopSize: {rfc2217Stop[cfg.StopBits]},
+		cpcSetControl:  {rfc2217Flow[cfg.FlowControl]},
+	}
+	var cmds []byte
+	for _, cmd := range []byte{cpcSetBaudrate, cpcSetDataSize, cpcSetParity, cpcSetStopSize, cpcSetControl} {
+		cmds = append(cmds, comPortCmd(cmd, want[cmd]...)...)
+	}
+	return want, cmds
+}
+
+// checkAcks 比较服务器确认的取值与请求的取值，调用方需持有 r.mu
+func (r *RFC2217Port) checkAcks(want map[byte][]byte) error {
+	for cmd, v := range want {
+		if got := r.acks[cmd]; !bytes.Equal(got, v) {
+			return fmt.Errorf("server rejected setting %d: want % X, got % X", cmd, v, got)
+		}
+	}
+	return nil
+}
+
+// acked 通知等待确认的 SetLineSettings，调用方需持有 r.mu
+func (r *RFC2217Port) acked() {
+	close(r.ackCh)
+	r.ackCh = make(chan struct{})
+}
+
+// waitFor 在截止时间前持续读取并处理服务器消息，直到 cond 成立；期间收到的数据留给 Read
+func (r *RFC2217Port) waitFor(c *rfc2217Conn, deadline time.Time, cond func() bool) error {
+	if err := c.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	buf := make([]byte, 512)
+	for {
+		r.mu.Lock()
+		ok := cond()
+		r.mu.Unlock()
+		if ok {
+			return nil
+		}
+		m, err := c.Read(buf)
+		n := c.codec.decode(buf, buf[:m])
+		c.pending = append(c.pending, buf[:n]...)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// onOption 处理选项协商：记录服务器对 COM-PORT-OPTION 的应答，拒绝未请求的选项
+func (r *RFC2217Port) onOption(verb, opt byte) {
+	switch opt {
+	case optComPort:
+		r.mu.Lock()
+		switch verb {
+		case tnDO:
+			r.comPort = 1
+		case tnDONT:
+			r.comPort = -1
+		}
+		r.mu.Unlock()
+	case optBinary, optSGA:
+		// 已主动请求，对方的确认无需应答
+	default:
+		switch verb {
+		case tnDO:
+			r.send(telnetOption(tnWONT, opt))
+		case tnWILL:
+			r.send(telnetOption(tnDONT, opt))
+		}
+	}
+}
+
+// onSub 处理服务器的 COM-PORT-OPTION 应答与通知
+func (r *RFC2217Port) onSub(opt byte, data []byte) {
+	if opt != optComPort || len(data) < 1 {
+		return
+	}
+	cmd, val := data[0], data[1:]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch cmd {
+	case cpcServer + cpcSetBaudrate, cpcServer + cpcSetDataSize, cpcServer + cpcSetParity, cpcServer + cpcSetStopSize:
+		r.acks[cmd-cpcServer] = append([]byte(nil), val...)
+		r.acked()
+	case cpcServer + cpcSetControl:
+		if len(val) != 1 {
+			return
+		}
+		switch val[0] {
+		case ctlFlowNone, ctlFlowXONXOFF, ctlFlowHardware:
+			r.acks[cpcSetControl] = []byte{val[0]}
+			r.acked()
+		case ctlDTROn:
+			r.outputs |= LineDTR
+		case ctlDTROff:
+			r.outputs &^= LineDTR
+		case ctlRTSOn:
+			r.outputs |= LineRTS
+		case ctlRTSOff:
+			r.outputs &^= LineRTS
+		}
+	case cpcServer + cpcNotifyLineState:
+		if len(val) != 1 {
+			return
+		}
+		ls := val[0]
+		if ls&lsOverrun != 0 {
+			r.counters.Overrun++
+		}
+		if ls&lsParity != 0 {
+			r.counters.Parity++
+		}
+		if ls&lsFraming != 0 {
+			r.counters.Frame++
+		}
+		if ls&lsBreak != 0 {
+			r.counters.Break++
+			r.brk = r.cfg.DetectBreak
+		}
+	case cpcServer + cpcNotifyModemState:
+		if len(val) != 1 {
+			return
+		}
+		if val[0]&0xF0 != r.modem&0xF0 {
+			close(r.modemCh)
+			r.modemCh = make(chan struct{})
+		}
+		r.modem = val[0]
+	}
+}
+
+// current 返回当前连接，供读循环、写入与 Close 并发访问
+func (r *RFC2217Port) current() *rfc2217Conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conn
+}
+
+// send 写入原始 Telnet 字节（调用方已完成转义）
+func (r *RFC2217Port) send(p []byte) error {
+	c := r.current()
+	if c == nil {
+		return net.ErrClosed
+	}
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	_, err := c.Write(p)
+	return err
+}
+
+// Close 断开连接
+func (r *RFC2217Port) Close() error {
+	r.mu.Lock()
+	c := r.conn
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	r.mu.Unlock()
+	if c != nil {
+		return c.Close()
+	}
+	return nil
+}
+
+// Read 读取解码后的串口数据；服务器报告 BREAK（且启用 detectBreak）时在已返回的字节之后返回 ErrBreak
+func (r *RFC2217Port) Read(p []byte) (int, error) {
+	c := r.current()
+	if c == nil {
+		return 0, net.ErrClosed
+	}
+	if len(c.pending) > 0 {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	for {
+		m, err := c.Read(p)
+		n := c.codec.decode(p, p[:m])
+		r.mu.Lock()
+		r.counters.Rx += uint32(n)
+		brk := r.brk
+		r.brk = false
+		r.mu.Unlock()
+		if brk {
+			return n, ErrBreak
+		}
+		if errors.Is(err, io.EOF) {
+			r.closed.Store(true)
+			return n, fmt.Errorf("%w: %w", errRemoteClosed, err)
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Write 转义 IAC 后写入
+func (r *RFC2217Port) Write(p []byte) (int, error) {
+	if err := r.send(escapeIAC(p)); err != nil {
+		return 0, fmt.Errorf("rfc2217 write failed: %w", err)
+	}
+	r.mu.Lock()
+	r.counters.Tx += uint32(len(p))
+	r.mu.Unlock()
+	return len(p), nil
+}
+
+// Name 返回逻辑名称
+func (r *RFC2217Port) Name() string {
+	return r.name
+}
+
+// ReadFrame 与 UART 一样把一次 Read 当作一帧
+func (r *RFC2217Port) ReadFrame() ([]byte, error) {
+	buf := make([]byte, 4096)
+	n, err := r.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// WriteFrame 写整帧
+func (r *RFC2217Port) WriteFrame(frame []byte) error {
+	_, err := r.Write(frame)
+	return err
+}
+
+// ModemLines 返回服务器最近上报的输入线与 DTR/RTS 状态
+func (r *RFC2217Port) ModemLines() (ModemLine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return modemFromRFC2217(r.modem) | r.outputs, nil
+}
+
+// SetModemLines 通过 SET-CONTROL 设置远端 DTR/RTS
+func (r *RFC2217Port) SetModemLines(mask ModemLine, on bool) error {
+	var cmds []byte
+	if mask&LineDTR != 0 {
+		cmds = append(cmds, comPortCmd(cpcSetControl, pick(on, ctlDTROn, ctlDTROff))...)
+	}
+	if mask&LineRTS != 0 {
+		cmds = append(cmds, comPortCmd(cpcSetControl, pick(on, ctlRTSOn, ctlRTSOff))...)
+	}
+	if err := r.send(cmds); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if on {
+		r.outputs |= mask & OutputLines
+	} else {
+		r.outputs &^= mask & OutputLines
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// pick 按条件选择 SET-CONTROL 取值
+func pick(on bool, a, b byte) byte {
+	if on {
+		return a
+	}
+	return b
+}
+
+// WaitModemChange 等待服务器上报输入线变化；连接关闭时返回错误
+func (r *RFC2217Port) WaitModemChange(mask ModemLine) error {
+	r.mu.Lock()
+	ch, done := r.modemCh, r.done
+	r.mu.Unlock()
+	if done == nil {
+		return net.ErrClosed
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-done:
+		return net.ErrClosed
+	}
+}
+
+// SendBreak 通过 SET-CONTROL 让服务器发送 BREAK
+func (r *RFC2217Port) SendBreak(d time.Duration) error {
+	if d == 0 {
+		d = defaultBreak
+	}
+	if err := r.send(comPortCmd(cpcSetControl, ctlBreakOn)); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return r.send(comPortCmd(cpcSetControl, ctlBreakOff))
+}
+
+// Counters 返回本端统计的收发字节与服务器上报的线路错误
+func (r *RFC2217Port) Counters() (Counters, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters, nil
+}
+
+// Probe 报告连接是否已被对端关闭
+func (r *RFC2217Port) Probe() error {
+	if r.closed.Load() {
+		return errRemoteClosed
+	}
+	return nil
+}
+
+// LineSettings 返回服务器最近确认的线路参数
+func (r *RFC2217Port) LineSettings() LineSettings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return lineSettingsOf(r.cfg)
+}
+
+// SetLineSettings 通过 SET-BAUDRATE/DATASIZE/PARITY/STOPSIZE/CONTROL 修改远端线路参数，
+// 等待读循环收到服务器的全部确认；确认值与请求一致时才更新本端配置，之后的重新连接沿用新参数。
+// interCharTimeoutMs/minRead 只影响本地读取，随配置保存但不下发
+func (r *RFC2217Port) SetLineSettings(ls LineSettings) error {
+	r.lmu.Lock()
+	defer r.lmu.Unlock()
+
+	r.mu.Lock()
+	cfg, err := withLineSettings(r.cfg, ls)
+	c, done := r.conn, r.done
+	r.acks = make(map[byte][]byte)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if c == nil || done == nil {
+		return net.ErrClosed
+	}
+	want, cmds := lineRequest(cfg)
+	if err := r.send(cmds); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(rfc2217Timeout)
+	defer timer.Stop()
+	for {
+		r.mu.Lock()
+		if len(r.acks) >= len(want) {
+			err := r.checkAcks(want)
+			if err == nil {
+				r.cfg = cfg
+			}
+			r.mu.Unlock()
+			return err
+		}
+		ch := r.ackCh
+		r.mu.Unlock()
+		select {
+		case <-ch:
+		case <-done:
+			return net.ErrClosed
+		case <-timer.C:
+			return fmt.Errorf("wait for line settings ack: %w", os.ErrDeadlineExceeded)
+		}
+	}
+}
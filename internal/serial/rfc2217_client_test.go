@@ -0,0 +1,437 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// rfc2217Stub 是最小的 RFC 2217 串口服务器：应答 COM-PORT-OPTION，
+// 按收到的取值确认线路参数，记录收到的选项、子协商与线上原始字节
+type rfc2217Stub struct {
+	t      *testing.T
+	ln     net.Listener
+	refuse bool            // 以 DONT 拒绝 COM-PORT-OPTION
+	acks   map[byte][]byte // 子命令 → 替代确认值，模拟服务器不接受请求的设置
+	conns  chan net.Conn
+
+	mu      sync.Mutex
+	options [][2]byte // 收到的 verb/opt
+	subs    [][]byte  // 收到的 COM-PORT-OPTION 子协商（cmd + 取值，已去转义）
+	raw     []byte    // 线上原始字节
+}
+
+func newRFC2217Stub(t *testing.T) *rfc2217Stub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &rfc2217Stub{t: t, ln: ln, acks: make(map[byte][]byte), conns: make(chan net.Conn, 1)}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *rfc2217Stub) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	s.t.Cleanup(func() { conn.Close() })
+	s.conns <- conn
+
+	codec := telnetCodec{
+		onOption: func(verb, opt byte) {
+			s.mu.Lock()
+			s.options = append(s.options, [2]byte{verb, opt})
+			s.mu.Unlock()
+			if verb == tnWILL && opt == optComPort {
+				conn.Write(telnetOption(pick(s.refuse, tnDONT, tnDO), optComPort))
+			}
+		},
+		onSub: func(opt byte, data []byte) {
+			if opt != optComPort || len(data) < 1 {
+				return
+			}
+			s.mu.Lock()
+			s.subs = append(s.subs, append([]byte(nil), data...))
+			s.mu.Unlock()
+			conn.Write(comPortCmd(cpcServer+data[0], s.reply(data[0], data[1:])...))
+		},
+	}
+	buf := make([]byte, 512)
+	for {
+		m, err := conn.Read(buf)
+		s.mu.Lock()
+		s.raw = append(s.raw, buf[:m]...)
+		s.mu.Unlock()
+		codec.decode(buf, buf[:m])
+		if err != nil {
+			return
+		}
+	}
+}
+
+// reply 返回对子命令的确认值：查询 DTR/RTS 时报告 DTR 有效、RTS 无效，其余原样确认
+func (s *rfc2217Stub) reply(cmd byte, val []byte) []byte {
+	if v, ok := s.acks[cmd]; ok {
+		return v
+	}
+	if cmd == cpcSetControl && len(val) == 1 {
+		switch val[0] {
+		case ctlDTRRequest:
+			return []byte{ctlDTROn}
+		case ctlRTSRequest:
+			return []byte{ctlRTSOff}
+		}
+	}
+	return val
+}
+
+// conn 返回客户端连接，用于向客户端发送数据与通知
+func (s *rfc2217Stub) conn() net.Conn {
+	s.t.Helper()
+	select {
+	case c := <-s.conns:
+		s.conns <- c
+		return c
+	case <-time.After(2 * time.Second):
+		s.t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+// sub 返回收到的第一个指定子命令的取值
+func (s *rfc2217Stub) sub(cmd byte) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sub := range s.subs {
+		if sub[0] == cmd {
+			return sub[1:], true
+		}
+	}
+	return nil, false
+}
+
+// lastSub 返回收到的最后一个指定子命令的取值
+func (s *rfc2217Stub) lastSub(cmd byte) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.subs) - 1; i >= 0; i-- {
+		if s.subs[i][0] == cmd {
+			return s.subs[i][1:], true
+		}
+	}
+	return nil, false
+}
+
+// waitRaw 等待线上收到 want
+func (s *rfc2217Stub) waitRaw(want []byte) {
+	s.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		ok := bytes.Contains(s.raw, want)
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t.Fatalf("server raw bytes % X do not contain % X", s.raw, want)
+}
+
+func rfc2217Config(addr string) config.Port {
+	return config.Port{
+		Name:        "R",
+		Type:        "rfc2217",
+		Address:     addr,
+		Baudrate:    115200,
+		DataBits:    8,
+		Parity:      config.ParityNone,
+		StopBits:    1,
+		FlowControl: config.FlowNone,
+	}
+}
+
+// openRFC2217 连接桩服务器并完成握手
+func openRFC2217(t *testing.T, s *rfc2217Stub, cfg config.Port) *RFC2217Port {
+	t.Helper()
+	p := NewRFC2217Port(cfg).(*RFC2217Port)
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestRFC2217ClientNegotiatesComPortOption(t *testing.T) {
+	s := newRFC2217Stub(t)
+	p := openRFC2217(t, s, rfc2217Config(s.ln.Addr().String()))
+
+	s.mu.Lock()
+	options := s.options
+	s.mu.Unlock()
+	for _, want := range [][2]byte{{tnWILL, optBinary}, {tnDO, optBinary}, {tnWILL, optSGA}, {tnDO, optSGA}, {tnWILL, optComPort}} {
+		found := false
+		for _, o := range options {
+			found = found || o == want
+		}
+		if !found {
+			t.Fatalf("client did not send IAC %d %d, got %v", want[0], want[1], options)
+		}
+	}
+	if v, ok := s.sub(cpcSetLineStateMask); !ok || !bytes.Equal(v, []byte{lsErrors}) {
+		t.Fatalf("SET-LINESTATE-MASK = % X, want %02X", v, lsErrors)
+	}
+	// 服务器报告 DTR 有效、RTS 无效
+	lines, _ := p.ModemLines()
+	if lines&OutputLines != LineDTR {
+		t.Fatalf("outputs = %v, want DTR only", lines&OutputLines)
+	}
+}
+
+func TestRFC2217ClientRefused(t *testing.T) {
+	s := newRFC2217Stub(t)
+	s.refuse = true
+	err := NewRFC2217Port(rfc2217Config(s.ln.Addr().String())).Open()
+	if err == nil || !strings.Contains(err.Error(), "refused COM-PORT-OPTION") {
+		t.Fatalf("Open error = %v, want COM-PORT-OPTION refusal", err)
+	}
+}
+
+func TestRFC2217ClientLineSettings(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		cfg    func(*config.Port)
+		baud   []byte
+		data   byte
+		parity byte
+		stop   byte
+		flow   byte
+	}{
+		{"8N1", func(*config.Port) {}, []byte{0x00, 0x01, 0xC2, 0x00}, 8, 1, 1, ctlFlowNone},
+		{"7E2 rtscts", func(c *config.Port) {
+			c.Baudrate, c.DataBits, c.Parity, c.StopBits, c.FlowControl = 9600, 7, config.ParityEven, 2, config.FlowRTSCTS
+		}, []byte{0x00, 0x00, 0x25, 0x80}, 7, 3, 2, ctlFlowHardware},
+		{"5O1.5 xonxoff", func(c *config.Port) {
+			c.Baudrate, c.DataBits, c.Parity, c.StopBits, c.FlowControl = 300, 5, config.ParityOdd, 1.5, config.FlowXONXOFF
+		}, []byte{0x00, 0x00, 0x01, 0x2C}, 5, 2, 3, ctlFlowXONXOFF},
+		{"mark", func(c *config.Port) { c.Parity = config.ParityMark }, []byte{0x00, 0x01, 0xC2, 0x00}, 8, 4, 1, ctlFlowNone},
+		{"space", func(c *config.Port) { c.Parity = config.ParitySpace }, []byte{0x00, 0x01, 0xC2, 0x00}, 8, 5, 1, ctlFlowNone},
+		// 波特率中的 0xFF 在子协商中转义为 IAC IAC
+		{"IAC in baudrate", func(c *config.Port) { c.Baudrate = 0x1FF }, []byte{0x00, 0x00, 0x01, 0xFF}, 8, 1, 1, ctlFlowNone},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newRFC2217Stub(t)
+			cfg := rfc2217Config(s.ln.Addr().String())
+			tc.cfg(&cfg)
+			openRFC2217(t, s, cfg)
+			for _, want := range []struct {
+				cmd byte
+				val []byte
+			}{
+				{cpcSetBaudrate, tc.baud},
+				{cpcSetDataSize, []byte{tc.data}},
+				{cpcSetParity, []byte{tc.parity}},
+				{cpcSetStopSize, []byte{tc.stop}},
+				{cpcSetControl, []byte{tc.flow}},
+			} {
+				if got, ok := s.sub(want.cmd); !ok || !bytes.Equal(got, want.val) {
+					t.Fatalf("subcommand %d = % X, want % X", want.cmd, got, want.val)
+				}
+			}
+			if bytes.IndexByte(tc.baud, tnIAC) >= 0 {
+				s.waitRaw([]byte{tnIAC, tnSB, optComPort, cpcSetBaudrate, 0x00, 0x00, 0x01, tnIAC, tnIAC, tnIAC, tnSE})
+			}
+		})
+	}
+}
+
+func TestRFC2217ClientRejectedSetting(t *testing.T) {
+	s := newRFC2217Stub(t)
+	s.acks[cpcSetBaudrate] = be32(9600)
+	err := NewRFC2217Port(rfc2217Config(s.ln.Addr().String())).Open()
+	if err == nil || !strings.Contains(err.Error(), "rejected setting") {
+		t.Fatalf("Open error = %v, want rejected baudrate", err)
+	}
+}
+
+func TestRFC2217ClientEscapesIAC(t *testing.T) {
+	s := newRFC2217Stub(t)
+	p := openRFC2217(t, s, rfc2217Config(s.ln.Addr().String()))
+
+	// 发送方向：0xFF 转义为 IAC IAC
+	if _, err := p.Write([]byte{0x01, 0xFF, 0x02}); err != nil {
+		t.Fatal(err)
+	}
+	s.waitRaw([]byte{0x01, tnIAC, tnIAC, 0x02})
+
+	// 接收方向：IAC IAC 还原为 0xFF，跨两次发送的转义也能还原
+	c := s.conn()
+	c.Write([]byte{0x10, tnIAC, tnIAC, 0x20, tnIAC})
+	time.Sleep(20 * time.Millisecond)
+	c.Write([]byte{tnIAC, 0x30})
+	var got []byte
+	buf := make([]byte, 64)
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	want := []byte{0x10, 0xFF, 0x20, 0xFF, 0x30}
+	for len(got) < len(want) {
+		n, err := p.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("read % X, want % X", got, want)
+	}
+}
+
+func TestRFC2217ClientNotifyLineState(t *testing.T) {
+	s := newRFC2217Stub(t)
+	cfg := rfc2217Config(s.ln.Addr().String())
+	cfg.DetectBreak = true
+	p := openRFC2217(t, s, cfg)
+
+	c := s.conn()
+	c.Write(append(comPortCmd(cpcServer+cpcNotifyLineState, lsParity|lsFraming), 'A'))
+	c.Write(append(comPortCmd(cpcServer+cpcNotifyLineState, lsOverrun|lsBreak), 'B'))
+
+	// 错误只计数，BREAK 在已收到的字节之后以 ErrBreak 返回
+	var got []byte
+	buf := make([]byte, 64)
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, err := p.Read(buf)
+		got = append(got, buf[:n]...)
+		if errors.Is(err, ErrBreak) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(got) != "AB" {
+		t.Fatalf("read %q before BREAK, want %q", got, "AB")
+	}
+	cnt, _ := p.Counters()
+	if cnt.Parity != 1 || cnt.Frame != 1 || cnt.Overrun != 1 || cnt.Break != 1 {
+		t.Fatalf("counters = %+v, want one parity, frame, overrun and break", cnt)
+	}
+	if cnt.Rx != 2 {
+		t.Fatalf("rx = %d, want 2", cnt.Rx)
+	}
+}
+
+func TestRFC2217ClientNotifyModemState(t *testing.T) {
+	s := newRFC2217Stub(t)
+	p := openRFC2217(t, s, rfc2217Config(s.ln.Addr().String()))
+
+	// 通知由读循环处理
+	readLoop(p)
+	changed := make(chan error, 1)
+	go func() { changed <- p.WaitModemChange(InputLines) }()
+	time.Sleep(20 * time.Millisecond)
+
+	s.conn().Write(comPortCmd(cpcServer+cpcNotifyModemState, msCTS|msCD|msCTS>>4|msCD>>4))
+	select {
+	case err := <-changed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitModemChange did not return after NOTIFY-MODEMSTATE")
+	}
+	lines, _ := p.ModemLines()
+	if lines&InputLines != LineCTS|LineDCD {
+		t.Fatalf("inputs = %v, want CTS|DCD", lines&InputLines)
+	}
+
+	// 连接关闭时等待返回错误
+	go func() { changed <- p.WaitModemChange(InputLines) }()
+	p.Close()
+	select {
+	case err := <-changed:
+		if err == nil {
+			t.Fatal("WaitModemChange returned nil after Close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitModemChange did not return after Close")
+	}
+}
+
+// readLoop 像监管器的读循环一样持续读取，服务器的确认与通知在其中处理
+func readLoop(p *RFC2217Port) {
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			if _, err := p.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestRFC2217ClientSetLineSettings(t *testing.T) {
+	s := newRFC2217Stub(t)
+	p := openRFC2217(t, s, rfc2217Config(s.ln.Addr().String()))
+	readLoop(p)
+
+	ls := p.LineSettings()
+	ls.Baudrate, ls.DataBits, ls.Parity, ls.StopBits, ls.FlowControl = 9600, 7, config.ParityEven, 2, config.FlowRTSCTS
+	if err := p.SetLineSettings(ls); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		cmd byte
+		val []byte
+	}{
+		{cpcSetBaudrate, be32(9600)},
+		{cpcSetDataSize, []byte{7}},
+		{cpcSetParity, []byte{3}},
+		{cpcSetStopSize, []byte{2}},
+		{cpcSetControl, []byte{ctlFlowHardware}},
+	} {
+		if got, ok := s.lastSub(want.cmd); !ok || !bytes.Equal(got, want.val) {
+			t.Fatalf("subcommand %d = % X, want % X", want.cmd, got, want.val)
+		}
+	}
+	if got := p.LineSettings(); got != ls {
+		t.Fatalf("LineSettings = %+v, want %+v", got, ls)
+	}
+}
+
+func TestRFC2217ClientSetLineSettingsRejected(t *testing.T) {
+	s := newRFC2217Stub(t)
+	// 服务器只接受无校验
+	s.acks[cpcSetParity] = []byte{rfc2217Parity[config.ParityNone]}
+	p := openRFC2217(t, s, rfc2217Config(s.ln.Addr().String()))
+	readLoop(p)
+
+	orig := p.LineSettings()
+	ls := orig
+	ls.Parity = config.ParityEven
+	err := p.SetLineSettings(ls)
+	if err == nil || !strings.Contains(err.Error(), "rejected setting") {
+		t.Fatalf("SetLineSettings error = %v, want rejected parity", err)
+	}
+	if got := p.LineSettings(); got != orig {
+		t.Fatalf("LineSettings = %+v after rejection, want %+v", got, orig)
+	}
+
+	// 连接关闭后不再等待确认
+	p.Close()
+	if err := p.SetLineSettings(ls); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("SetLineSettings after Close = %v, want net.ErrClosed", err)
+	}
+}
@@ -0,0 +1,286 @@
+package serial
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxRemoteBreak 限制客户端 BREAK ON/OFF 之间换算出的 BREAK 时长
+const maxRemoteBreak = 5 * time.Second
+
+// rfc2217Session 是共享端口上一个 RFC 2217 客户端的会话（服务器端）：
+//   - SET-BAUDRATE/DATASIZE/PARITY/STOPSIZE 与流控经共享服务器的 PortAccess 作用于底层端口，与其他写入互斥
+//   - DTR/RTS 与 BREAK 通过 ModemController/BreakSender 执行
+//   - 输入线变化（订阅端口的 ModemHub）与收到的 BREAK 按客户端设置的掩码上报
+//   - 会话结束时恢复首次修改前的线路参数（期间被他人修改过则保留）与 DTR/RTS
+type rfc2217Session struct {
+	s     *ShareServer
+	c     *shareClient
+	codec telnetCodec
+
+	orig      *LineSettings // 首次修改前的线路参数
+	set       *LineSettings // 会话最近一次设置生效的线路参数
+	origLines *ModemLine    // 首次修改前的 DTR/RTS
+	breakOn   time.Time     // 客户端 BREAK ON 的时间
+	unwatch   func()        // 取消输入线变化订阅
+
+	mu     sync.Mutex
+	lsMask byte
+	msMask byte
+}
+
+func newRFC2217Session(s *ShareServer, c *shareClient) *rfc2217Session {
+	x := &rfc2217Session{s: s, c: c, msMask: 0xFF}
+	x.codec = telnetCodec{onOption: x.onOption, onSub: x.onSub}
+	return x
+}
+
+// start 发起选项协商并订阅输入线变化
+func (x *rfc2217Session) start() {
+	var neg []byte
+	neg = append(neg, telnetOption(tnDO, optComPort)...)
+	neg = append(neg, telnetOption(tnWILL, optBinary)...)
+	neg = append(neg, telnetOption(tnDO, optBinary)...)
+	neg = append(neg, telnetOption(tnWILL, optSGA)...)
+	neg = append(neg, telnetOption(tnDO, optSGA)...)
+	x.reply(neg)
+
+	x.unwatch = x.s.modem.Subscribe(func(old, cur ModemLine) {
+		x.mu.Lock()
+		mask := x.msMask
+		x.mu.Unlock()
+		if b := modemToRFC2217(cur, old^cur) & mask; b != 0 {
+			x.reply(comPortCmd(cpcServer+cpcNotifyModemState, b))
+		}
+	})
+}
+
+// end 取消订阅并恢复会话期间修改过的端口参数
+func (x *rfc2217Session) end() {
+	x.unwatch()
+	name := x.s.sup.Name()
+	if x.orig != nil {
+		// 只有参数仍是会话设置的值时才恢复，不覆盖会话期间经 MQTT/REST 做的修改
+		restored := false
+		_, err := x.s.out.UpdateLine(func(cur LineSettings) (LineSettings, bool) {
+			restored = cur == *x.set
+			return *x.orig, restored
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("⚠️ [%s] rfc2217 restore line settings failed: %v\n", name, err)
+		case restored:
+			fmt.Printf("🔧 [%s] rfc2217 session ended, line settings restored\n", name)
+		default:
+			fmt.Printf("🔧 [%s] rfc2217 session ended, line settings changed since, kept\n", name)
+		}
+	}
+	if x.origLines != nil {
+		if mc, ok := x.s.sup.Port().(ModemController); ok {
+			for _, l := range []ModemLine{LineDTR, LineRTS} {
+				if err := mc.SetModemLines(l, *x.origLines&l != 0); err != nil {
+					fmt.Printf("⚠️ [%s] rfc2217 restore %s failed: %v\n", name, l, err)
+				}
+			}
+		}
+	}
+}
+
+// reply 发送给客户端
+func (x *rfc2217Session) reply(p []byte) {
+	x.s.reply(x.c, p)
+}
+
+// notifyLine 按客户端的线路状态掩码上报
+func (x *rfc2217Session) notifyLine(ls byte) {
+	x.mu.Lock()
+	mask := x.lsMask
+	x.mu.Unlock()
+	if b := ls & mask; b != 0 {
+		x.reply(comPortCmd(cpcServer+cpcNotifyLineState, b))
+	}
+}
+
+// onOption 处理选项协商：COM-PORT/BINARY/SGA 已主动提出，其余选项一律拒绝
+func (x *rfc2217Session) onOption(verb, opt byte) {
+	switch opt {
+	case optComPort, optBinary, optSGA:
+	default:
+		switch verb {
+		case tnDO:
+			x.reply(telnetOption(tnWONT, opt))
+		case tnWILL:
+			x.reply(telnetOption(tnDONT, opt))
+		}
+	}
+}
+
+// lineSettings 返回端口当前的线路参数
+func (x *rfc2217Session) lineSettings() LineSettings {
+	if lc, ok := x.s.sup.Port().(LineConfigurer); ok {
+		return lc.LineSettings()
+	}
+	return x.s.line
+}
+
+// change 经端口的独占访问路径修改线路参数；首次修改前记录原值用于会话结束时恢复
+func (x *rfc2217Session) change(fn func(ls *LineSettings)) {
+	var before LineSettings
+	applied, err := x.s.out.UpdateLine(func(cur LineSettings) (LineSettings, bool) {
+		before = cur
+		next := cur
+		fn(&next)
+		return next, next != cur
+	})
+	if err != nil {
+		fmt.Printf("⚠️ [%s] rfc2217 set line settings failed: %v\n", x.s.sup.Name(), err)
+		return
+	}
+	if applied == before {
+		return
+	}
+	if x.orig == nil {
+		x.orig = &before
+	}
+	x.set = &applied
+}
+
+// onSub 处理客户端的 COM-PORT-OPTION 子命令并应答当前实际值
+func (x *rfc2217Session) onSub(opt byte, data []byte) {
+	if opt != optComPort || len(data) == 0 {
+		return
+	}
+	cmd, val := data[0], data[1:]
+	switch cmd {
+	case cpcSignature:
+		x.reply(comPortCmd(cpcServer+cpcSignature, []byte("device_uart_go "+x.s.sup.Name())...))
+	case cpcSetBaudrate:
+		if len(val) == 4 {
+			if v := binary.BigEndian.Uint32(val); v != 0 {
+				x.change(func(ls *LineSettings) { ls.Baudrate = int(v) })
+			}
+		}
+		x.reply(comPortCmd(cpcServer+cpcSetBaudrate, be32(uint32(x.lineSettings().Baudrate))...))
+	case cpcSetDataSize:
+		if len(val) == 1 && val[0] != 0 {
+			x.change(func(ls *LineSettings) { ls.DataBits = int(val[0]) })
+		}
+		x.reply(comPortCmd(cpcServer+cpcSetDataSize, byte(x.lineSettings().DataBits)))
+	case cpcSetParity:
+		if len(val) == 1 {
+			if p, ok := reverseLookup(rfc2217Parity, val[0]); ok {
+				x.change(func(ls *LineSettings) { ls.Parity = p })
+			}
+		}
+		x.reply(comPortCmd(cpcServer+cpcSetParity, rfc2217Parity[x.lineSettings().Parity]))
+	case cpcSetStopSize:
+		if len(val) == 1 {
+			if sb, ok := reverseLookup(rfc2217Stop, val[0]); ok {
+				x.change(func(ls *LineSettings) { ls.StopBits = sb })
+			}
+		}
+		x.reply(comPortCmd(cpcServer+cpcSetStopSize, rfc2217Stop[x.lineSettings().StopBits]))
+	case cpcSetControl:
+		if len(val) == 1 {
+			x.reply(comPortCmd(cpcServer+cpcSetControl, x.control(val[0])))
+		}
+	case cpcSetLineStateMask:
+		if len(val) == 1 {
+			x.mu.Lock()
+			x.lsMask = val[0]
+			x.mu.Unlock()
+			x.reply(comPortCmd(cpcServer+cpcSetLineStateMask, val[0]))
+		}
+	case cpcSetModemStateMask:
+		if len(val) == 1 {
+			x.mu.Lock()
+			x.msMask = val[0]
+			x.mu.Unlock()
+			x.reply(comPortCmd(cpcServer+cpcSetModemStateMask, val[0]))
+		}
+	case cpcPurgeData:
+		if len(val) == 1 {
+			x.reply(comPortCmd(cpcServer+cpcPurgeData, val[0]))
+		}
+	case cpcFlowSuspend, cpcFlowResume:
+		// 数据经有界队列发送，慢客户端会被断开，无需流控
+	}
+}
+
+// control 执行 SET-CONTROL 并返回应答值
+func (x *rfc2217Session) control(v byte) byte {
+	switch v {
+	case ctlFlowRequest:
+		return rfc2217Flow[x.lineSettings().FlowControl]
+	case ctlFlowNone, ctlFlowXONXOFF, ctlFlowHardware:
+		if f, ok := reverseLookup(rfc2217Flow, v); ok {
+			x.change(func(ls *LineSettings) { ls.FlowControl = f })
+		}
+		return rfc2217Flow[x.lineSettings().FlowControl]
+	case ctlBreakRequest:
+		return pick(!x.breakOn.IsZero(), ctlBreakOn, ctlBreakOff)
+	case ctlBreakOn:
+		x.breakOn = time.Now()
+		return ctlBreakOn
+	case ctlBreakOff:
+		if !x.breakOn.IsZero() {
+			// BREAK 以阻塞调用发送，按客户端 ON/OFF 的间隔换算时长
+			d := min(time.Since(x.breakOn), maxRemoteBreak)
+			x.breakOn = time.Time{}
+			if bs, ok := x.s.sup.Port().(BreakSender); ok {
+				if err := bs.SendBreak(d); err != nil {
+					fmt.Printf("⚠️ [%s] rfc2217 send break failed: %v\n", x.s.sup.Name(), err)
+				}
+			}
+		}
+		return ctlBreakOff
+	case ctlDTRRequest:
+		return pick(x.modemLines()&LineDTR != 0, ctlDTROn, ctlDTROff)
+	case ctlDTROn, ctlDTROff:
+		x.setOutput(LineDTR, v == ctlDTROn)
+		return pick(x.modemLines()&LineDTR != 0, ctlDTROn, ctlDTROff)
+	case ctlRTSRequest:
+		return pick(x.modemLines()&LineRTS != 0, ctlRTSOn, ctlRTSOff)
+	case ctlRTSOn, ctlRTSOff:
+		x.setOutput(LineRTS, v == ctlRTSOn)
+		return pick(x.modemLines()&LineRTS != 0, ctlRTSOn, ctlRTSOff)
+	default:
+		// 入向流控等未实现的取值原样确认
+		return v
+	}
+}
+
+// modemLines 读取端口当前的调制解调器线，不支持时返回 0
+func (x *rfc2217Session) modemLines() ModemLine {
+	mc, ok := x.s.sup.Port().(ModemController)
+	if !ok || !x.s.sup.Connected() {
+		return 0
+	}
+	m, err := mc.ModemLines()
+	if err != nil {
+		return 0
+	}
+	return m
+}
+
+// setOutput 设置 DTR/RTS；首次修改前记录原值用于会话结束时恢复
+func (x *rfc2217Session) setOutput(line ModemLine, on bool) {
+	mc, ok := x.s.sup.Port().(ModemController)
+	if !ok || !x.s.sup.Connected() {
+		return
+	}
+	if x.origLines == nil {
+		cur, err := mc.ModemLines()
+		if err != nil {
+			fmt.Printf("⚠️ [%s] rfc2217 read modem lines failed: %v\n", x.s.sup.Name(), err)
+			return
+		}
+		cur &= OutputLines
+		x.origLines = &cur
+	}
+	if err := mc.SetModemLines(line, on); err != nil {
+		fmt.Printf("⚠️ [%s] rfc2217 set %s failed: %v\n", x.s.sup.Name(), line, err)
+	}
+}
@@ -0,0 +1,55 @@
+package serial
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeAccess 记录线路参数，模拟驱动注入的端口访问路径
+type fakeAccess struct {
+	cur LineSettings
+}
+
+func (f *fakeAccess) Write(p []byte) (int, error) { return len(p), nil }
+
+func (f *fakeAccess) UpdateLine(update func(cur LineSettings) (LineSettings, bool)) (LineSettings, error) {
+	if next, ok := update(f.cur); ok {
+		f.cur = next
+	}
+	return f.cur, nil
+}
+
+// newTestSession 创建一个经 fakeAccess 修改线路参数的 RFC 2217 会话
+func newTestSession(t *testing.T) (*rfc2217Session, *fakeAccess) {
+	t.Helper()
+	acc := &fakeAccess{cur: LineSettings{Baudrate: 115200, DataBits: 8, Parity: "none", StopBits: 1, FlowControl: "none"}}
+	sup := NewSupervisor(&hotplugPort{name: "S"}, time.Hour, time.Hour)
+	s := &ShareServer{sup: sup, out: acc, monitors: make(map[*shareClient]struct{})}
+	x := newRFC2217Session(s, &shareClient{})
+	x.start()
+	return x, acc
+}
+
+func TestRFC2217SessionRestoresOwnChange(t *testing.T) {
+	x, acc := newTestSession(t)
+	x.onSub(optComPort, append([]byte{cpcSetBaudrate}, be32(9600)...))
+	x.onSub(optComPort, []byte{cpcSetParity, 3})
+	if acc.cur.Baudrate != 9600 || acc.cur.Parity != "even" {
+		t.Fatalf("settings = %+v, want 9600 even", acc.cur)
+	}
+	x.end()
+	if acc.cur.Baudrate != 115200 || acc.cur.Parity != "none" {
+		t.Fatalf("settings after end = %+v, want original", acc.cur)
+	}
+}
+
+func TestRFC2217SessionKeepsLaterChange(t *testing.T) {
+	x, acc := newTestSession(t)
+	x.onSub(optComPort, append([]byte{cpcSetBaudrate}, be32(9600)...))
+	// 会话期间经 MQTT/REST 修改了参数
+	acc.cur.Baudrate = 19200
+	x.end()
+	if acc.cur.Baudrate != 19200 {
+		t.Fatalf("baudrate after end = %d, want the later change 19200 kept", acc.cur.Baudrate)
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/linjuya-lu/device_uart_go/internal/config"
-	aliasserial "github.com/tarm/serial"
 )
 
 // RS232Port 实现了标准 RS-232 全双工串口的帧级收发：
@@ -16,47 +16,65 @@ import (
 // - ReadFrame/WriteFrame 支持按帧自动解析/发送
 
 type RS232Port struct {
+	name string     // 逻辑名称，构造后不变，读取无需加锁
+	lmu  sync.Mutex // 保护 cfg 与 port：监管器重新打开时替换句柄，线路参数可能被并发修改
 	cfg  config.Port
-	port *aliasserial.Port
+	port rawPort
+	echo *echoCanceller // 回显抑制，未启用时为 nil
+	brk  *breakDecoder  // BREAK 检测，未启用时为 nil
 }
 
 // NewRS232Port 构造 RS232Port
 func NewRS232Port(cfg config.Port) Port {
-	return &RS232Port{cfg: cfg}
+	return &RS232Port{name: cfg.Name, cfg: cfg, echo: newEchoCanceller(cfg), brk: newBreakDecoder(cfg.DetectBreak)}
 }
 
 // Open 打开并配置串口
 func (r *RS232Port) Open() error {
-	sc := &aliasserial.Config{
-		Name:        r.cfg.Device,
-		Baud:        r.cfg.Baudrate,
-		ReadTimeout: time.Duration(r.cfg.TimeoutMs) * time.Millisecond,
-	}
-	p, err := aliasserial.OpenPort(sc)
+	r.lmu.Lock()
+	cfg := r.cfg
+	r.lmu.Unlock()
+	p, err := openSerialPort(cfg)
 	if err != nil {
-		return fmt.Errorf("open serial %s failed: %w", r.cfg.Device, err)
+		return fmt.Errorf("open serial %s failed: %w", cfg.DeviceLabel(), err)
 	}
+	r.lmu.Lock()
 	r.port = p
+	r.lmu.Unlock()
 	return nil
 }
 
+// raw 返回当前句柄，供读循环、调制解调器监视、计数轮询等并发访问
+func (r *RS232Port) raw() rawPort {
+	r.lmu.Lock()
+	defer r.lmu.Unlock()
+	return r.port
+}
+
 // Close 关闭串口
 func (r *RS232Port) Close() error {
-	if r.port != nil {
-		return r.port.Close()
+	if h := r.raw(); h != nil {
+		return h.Close()
 	}
 	return nil
 }
 
 // Read 读取原始字节，实现 io.Reader
 func (r *RS232Port) Read(p []byte) (int, error) {
-	return r.port.Read(p)
+	return r.echo.read(p, r.readDecoded)
+}
+
+// readDecoded 读取并解码 BREAK 标记
+func (r *RS232Port) readDecoded(p []byte) (int, error) {
+	return r.brk.read(p, r.raw().Read)
 }
 
 // Write 写入原始字节，实现 io.Writer
 func (r *RS232Port) Write(p []byte) (int, error) {
-	n, err := r.port.Write(p)
+	r.echo.expect(p)
+	n, err := r.raw().Write(p)
 	if err != nil {
+		r.echo.cancel()
 		return n, fmt.Errorf("serial write failed: %w", err)
 	}
 	return n, nil
@@ -64,14 +82,14 @@ func (r *RS232Port) Write(p []byte) (int, error) {
 
 // Name 返回逻辑名称
 func (r *RS232Port) Name() string {
-	return r.cfg.Name
+	return r.name
 }
 
 // ReadFrame 按自定义协议自动组帧读取：
 // 固定帧头 0x10 (6 字节)
 // 可变帧头 0x68 (先 4 字节 header，再读 length+2)
 func (r *RS232Port) ReadFrame() ([]byte, error) {
-	reader := bufio.NewReader(r.port)
+	reader := bufio.NewReader(r)
 	hdr, err := reader.Peek(1)
 	if err != nil {
 		return nil, err
@@ -109,8 +127,58 @@ func (r *RS232Port) ReadFrame() ([]byte, error) {
 
 // WriteFrame 直接写整帧数据
 func (r *RS232Port) WriteFrame(frame []byte) error {
-	if _, err := r.port.Write(frame); err != nil {
+	if _, err := r.Write(frame); err != nil {
 		return fmt.Errorf("serial write frame failed: %w", err)
 	}
 	return nil
 }
+
+// ModemLines 读取调制解调器控制/状态线
+func (r *RS232Port) ModemLines() (ModemLine, error) {
+	return getModemLines(r.raw().Fd())
+}
+
+// SetModemLines 设置 DTR/RTS
+func (r *RS232Port) SetModemLines(mask ModemLine, on bool) error {
+	return setModemLines(r.raw().Fd(), mask, on)
+}
+
+// WaitModemChange 等待输入线变化
+func (r *RS232Port) WaitModemChange(mask ModemLine) error {
+	return waitModemChange(r.raw().Fd(), mask)
+}
+
+// SendBreak 发送 BREAK
+func (r *RS232Port) SendBreak(d time.Duration) error {
+	return sendBreak(r.raw().Fd(), d)
+}
+
+// Counters 读取驱动的收发与错误计数
+func (r *RS232Port) Counters() (Counters, error) {
+	return readCounters(r.raw().Fd(), r.echo)
+}
+
+// Probe 检查设备是否仍然存在
+func (r *RS232Port) Probe() error {
+	return probeTTY(r.raw().Fd())
+}
+
+// LineSettings 返回当前线路参数
+func (r *RS232Port) LineSettings() LineSettings {
+	r.lmu.Lock()
+	defer r.lmu.Unlock()
+	return lineSettingsOf(r.cfg)
+}
+
+// SetLineSettings 在运行时修改线路参数
+func (r *RS232Port) SetLineSettings(ls LineSettings) error {
+	r.lmu.Lock()
+	defer r.lmu.Unlock()
+	cfg, err := reconfigure(r.port, r.cfg, ls)
+	if err != nil {
+		return err
+	}
+	r.cfg = cfg
+	r.echo.reconfigured(cfg)
+	return nil
+}
@@ -4,75 +4,118 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/linjuya-lu/device_uart_go/internal/config"
-	"github.com/tarm/serial"
 )
 
+// drainTxFn 等待发送器排空，测试中替换以记录切回接收的时机
+var drainTxFn = drainTx
+
 // RS485Port 实现了 RS-485 半双工物理层的帧级读写
-// - Open/Close 管理串口和 GPIO
+// - Open/Close 管理串口和方向控制（内核 RS-485 或 GPIO）
 // - Read/Write 提供原始字节接口
 // - ReadFrame/WriteFrame 提供按帧读写接口
 
 type RS485Port struct {
-	cfg    config.Port  // 端口配置
-	port   *serial.Port // 串口句柄
-	gpioFD *os.File     // DE/RE 控制 GPIO 节点
-	buf    []byte       // 缓存用于帧级解析
+	name      string         // 逻辑名称，构造后不变
+	mode      string         // 方向控制方式，构造后不变
+	cfg       config.Port    // 端口配置，线路参数可在运行时修改，由 wmu 保护
+	port      rawPort        // 串口句柄
+	dir       *dirControl    // DE/RE 方向控制（gpio 模式）
+	prevRS485 *serialRS485   // 打开前的内核 RS-485 配置（kernel 模式）
+	buf       []byte         // 缓存用于帧级解析
+	wmu       sync.Mutex     // 串行化发送，避免两次发送交错翻转方向
+	pmu       sync.Mutex     // 保护 port/dir：监管器重新打开时替换，读循环与各类 ioctl 并发访问
+	echo      *echoCanceller // 回显抑制，未启用时为 nil
+	brk       *breakDecoder  // BREAK 检测，未启用时为 nil
 }
 
 // 构造 RS485Port 实例
 func NewRS485Port(cfg config.Port) Port {
-	return &RS485Port{cfg: cfg, buf: make([]byte, 0)}
+	return &RS485Port{name: cfg.Name, mode: cfg.RS485Mode, cfg: cfg, buf: make([]byte, 0), echo: newEchoCanceller(cfg), brk: newBreakDecoder(cfg.DetectBreak)}
 }
 
-// Open 导出 GPIO 并打开串口
+// Open 按 rs485Mode 打开串口：kernel 模式由驱动切换方向，
+// gpio 模式通过 gpiochip 字符设备（de/re）或 sysfs（dePin）控制方向
 func (r *RS485Port) Open() error {
-	// 导出 GPIO
-	if err := exportGPIO(r.cfg.DEPin); err != nil {
-		return fmt.Errorf("export GPIO %d failed: %w", r.cfg.DEPin, err)
-	}
-	time.Sleep(100 * time.Millisecond)
-	if err := setGPIODirection(r.cfg.DEPin, "out"); err != nil {
-		return fmt.Errorf("set GPIO %d direction: %w", r.cfg.DEPin, err)
+	// 重新打开时沿用运行时修改过的线路参数
+	r.wmu.Lock()
+	cfg := r.cfg
+	r.wmu.Unlock()
+	if r.mode == config.RS485Kernel {
+		return r.openKernel(cfg)
 	}
-	f, err := openGPIOValue(r.cfg.DEPin)
+	// 申请方向控制引脚，默认处于接收状态
+	dir, err := openDirControl(cfg)
 	if err != nil {
-		return fmt.Errorf("open GPIO %d value: %w", r.cfg.DEPin, err)
-	}
-	// 默认低电平 (接收)
-	if _, err := f.WriteString("0"); err != nil {
-		f.Close()
-		return fmt.Errorf("init GPIO %d low: %w", r.cfg.DEPin, err)
+		return err
 	}
-	r.gpioFD = f
 
 	// 打开串口
-	serCfg := &serial.Config{
-		Name:        r.cfg.Device,
-		Baud:        r.cfg.Baudrate,
-		ReadTimeout: time.Duration(r.cfg.TimeoutMs) * time.Millisecond,
+	p, err := openSerialPort(cfg)
+	if err != nil {
+		dir.Close()
+		return fmt.Errorf("open serial %s failed: %w", cfg.DeviceLabel(), err)
 	}
-	p, err := serial.OpenPort(serCfg)
+	r.pmu.Lock()
+	r.port, r.dir = p, dir
+	r.pmu.Unlock()
+	return nil
+}
+
+// openKernel 打开串口并通过 TIOCSRS485 启用内核 RS-485 模式
+// 驱动不支持时直接报错，不会悄悄退回 GPIO 方式
+func (r *RS485Port) openKernel(cfg config.Port) error {
+	p, err := openSerialPort(cfg)
 	if err != nil {
-		r.gpioFD.Close()
-		return fmt.Errorf("open serial %s failed: %w", r.cfg.Device, err)
+		return fmt.Errorf("open serial %s failed: %w", cfg.DeviceLabel(), err)
 	}
-	r.port = p
+	prev, err := enableKernelRS485(int(p.Fd()), cfg)
+	if err != nil {
+		p.Close()
+		return fmt.Errorf("enable kernel RS-485 on %s: %w", cfg.DeviceLabel(), err)
+	}
+	r.pmu.Lock()
+	r.port, r.prevRS485 = p, prev
+	r.pmu.Unlock()
 	return nil
 }
 
+// handles 返回当前的串口句柄和方向控制
+func (r *RS485Port) handles() (rawPort, *dirControl) {
+	r.pmu.Lock()
+	defer r.pmu.Unlock()
+	return r.port, r.dir
+}
+
+// raw 返回当前串口句柄
+func (r *RS485Port) raw() rawPort {
+	p, _ := r.handles()
+	return p
+}
+
 // Close 关闭串口和 GPIO
 func (r *RS485Port) Close() error {
+	r.pmu.Lock()
+	port, dir, prev := r.port, r.dir, r.prevRS485
+	r.dir, r.prevRS485 = nil, nil
+	r.pmu.Unlock()
+
 	var firstErr error
-	if r.port != nil {
-		if err := r.port.Close(); err != nil {
+	if port != nil && prev != nil {
+		if err := restoreKernelRS485(int(port.Fd()), prev); err != nil {
+			firstErr = err
+		}
+	}
+	if port != nil {
+		if err := port.Close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
 	}
-	if r.gpioFD != nil {
-		if err := r.gpioFD.Close(); err != nil && firstErr == nil {
+	if dir != nil {
+		if err := dir.Close(); err != nil && firstErr == nil {
 			firstErr = err
 		}
 	}
@@ -81,19 +124,73 @@ func (r *RS485Port) Close() error {
 
 // Read 实现 io.Reader
 func (r *RS485Port) Read(p []byte) (int, error) {
-	return r.port.Read(p)
+	return r.echo.read(p, r.readDecoded)
+}
+
+// readDecoded 读取并解码 BREAK 标记
+func (r *RS485Port) readDecoded(p []byte) (int, error) {
+	return r.brk.read(p, r.raw().Read)
 }
 
-// Write 实现 io.Writer，注意并不会自动切换 DE/RE
+// Write 实现 io.Writer，所有发送路径都经过这里完成半双工方向切换：
+//   - kernel 模式由驱动切换 RTS，直接写入
+//   - gpio 模式切到发送 → 写入 → 等发送器排空（tcdrain/LSR）→ 切回接收
 func (r *RS485Port) Write(p []byte) (int, error) {
-	return r.port.Write(p)
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+
+	port, dir := r.handles()
+	r.echo.expect(p)
+	if r.mode == config.RS485Kernel {
+		n, err := port.Write(p)
+		if err != nil {
+			r.echo.cancel()
+		}
+		return n, err
+	}
+
+	if dir == nil {
+		// 端口已关闭，方向控制引脚已释放
+		r.echo.cancel()
+		return 0, os.ErrClosed
+	}
+	// 切到发送
+	if err := dir.transmit(); err != nil {
+		r.echo.cancel()
+		return 0, err
+	}
+	if r.cfg.DelayBeforeSendMs > 0 {
+		time.Sleep(time.Duration(r.cfg.DelayBeforeSendMs) * time.Millisecond)
+	}
+
+	n, err := port.Write(p)
+	if err != nil {
+		// 出错切回接收
+		r.echo.cancel()
+		dir.receive()
+		return n, fmt.Errorf("serial write failed: %w", err)
+	}
+	// 等待所有比特真正发出后再释放总线
+	if err := drainTxFn(int(port.Fd()), charTime(r.cfg)); err != nil {
+		dir.receive()
+		return n, err
+	}
+	if r.cfg.DelayAfterSendMs > 0 {
+		time.Sleep(time.Duration(r.cfg.DelayAfterSendMs) * time.Millisecond)
+	}
+
+	// 切回接收
+	if err := dir.receive(); err != nil {
+		return n, err
+	}
+	return n, nil
 }
 
 // ReadFrame 按 IEC101 (0x68…0x16) 协议从缓存+串口中提取完整帧
 func (r *RS485Port) ReadFrame() ([]byte, error) {
 	// 读入新数据
 	tmp := make([]byte, 256)
-	n, err := r.port.Read(tmp)
+	n, err := r.Read(tmp)
 	if err != nil {
 		return nil, err
 	}
@@ -123,58 +220,85 @@ func (r *RS485Port) ReadFrame() ([]byte, error) {
 	return frame, nil
 }
 
-// WriteFrame 切到发送 → 写整帧 → 切回接收
+// WriteFrame 写整帧，方向切换由 Write 完成
 func (r *RS485Port) WriteFrame(frame []byte) error {
-	// 切到发送
-	if _, err := r.gpioFD.WriteString("1"); err != nil {
-		return fmt.Errorf("GPIO DE high failed: %w", err)
-	}
-	time.Sleep(5 * time.Millisecond)
-
-	n, err := r.port.Write(frame)
-	if err != nil {
-		// 出错切回接收
-		r.gpioFD.WriteString("0")
-		return fmt.Errorf("serial write failed: %w", err)
-	}
-	// 等待所有比特发出 (10 bits/byte)
-	time.Sleep(time.Duration(n*10) * time.Second / time.Duration(r.cfg.Baudrate))
-
-	// 切回接收
-	if _, err := r.gpioFD.WriteString("0"); err != nil {
-		return fmt.Errorf("GPIO DE low failed: %w", err)
+	if _, err := r.Write(frame); err != nil {
+		return err
 	}
 	return nil
 }
 
 // Name 返回端口名称
 func (r *RS485Port) Name() string {
-	return r.cfg.Name
+	return r.name
 }
 
-// -------- GPIO 辅助函数 --------
-func exportGPIO(pin int) error {
-	f, err := os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, 0)
-	if err != nil {
-		return err
+// ModemLines 读取调制解调器控制/状态线
+func (r *RS485Port) ModemLines() (ModemLine, error) {
+	return getModemLines(r.raw().Fd())
+}
+
+// SetModemLines 设置 DTR/RTS；kernel 模式下 RTS 由驱动用于方向控制，不允许手动设置
+func (r *RS485Port) SetModemLines(mask ModemLine, on bool) error {
+	if r.mode == config.RS485Kernel && mask&LineRTS != 0 {
+		return fmt.Errorf("RTS is driven by kernel RS-485 mode on %s", r.name)
 	}
-	defer f.Close()
-	_, _ = f.WriteString(fmt.Sprint(pin)) // 若已导出则忽略错误
-	return nil
+	return setModemLines(r.raw().Fd(), mask, on)
 }
 
-func setGPIODirection(pin int, dir string) error {
-	path := fmt.Sprintf("/sys/class/gpio/gpio%d/direction", pin)
-	f, err := os.OpenFile(path, os.O_WRONLY, 0)
-	if err != nil {
+// WaitModemChange 等待输入线变化
+func (r *RS485Port) WaitModemChange(mask ModemLine) error {
+	return waitModemChange(r.raw().Fd(), mask)
+}
+
+// SendBreak 发送 BREAK，gpio 模式下发送期间使能驱动器
+func (r *RS485Port) SendBreak(d time.Duration) error {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	port, dir := r.handles()
+
+	if r.mode == config.RS485Kernel {
+		return sendBreak(port.Fd(), d)
+	}
+	if dir == nil {
+		return os.ErrClosed
+	}
+	if err := dir.transmit(); err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = f.WriteString(dir)
+	err := sendBreak(port.Fd(), d)
+	if rerr := dir.receive(); err == nil {
+		err = rerr
+	}
 	return err
 }
 
-func openGPIOValue(pin int) (*os.File, error) {
-	path := fmt.Sprintf("/sys/class/gpio/gpio%d/value", pin)
-	return os.OpenFile(path, os.O_RDWR, 0)
+// Counters 读取驱动的收发与错误计数
+func (r *RS485Port) Counters() (Counters, error) {
+	return readCounters(r.raw().Fd(), r.echo)
+}
+
+// Probe 检查设备是否仍然存在
+func (r *RS485Port) Probe() error {
+	return probeTTY(r.raw().Fd())
+}
+
+// LineSettings 返回当前线路参数
+func (r *RS485Port) LineSettings() LineSettings {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	return lineSettingsOf(r.cfg)
+}
+
+// SetLineSettings 在运行时修改线路参数；与发送互斥，保证排空时间按新波特率计算
+func (r *RS485Port) SetLineSettings(ls LineSettings) error {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+	cfg, err := reconfigure(r.raw(), r.cfg, ls)
+	if err != nil {
+		return err
+	}
+	r.cfg = cfg
+	r.echo.reconfigured(cfg)
+	return nil
 }
@@ -0,0 +1,60 @@
+package serial
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// struct serial_rs485 中的 flags
+const (
+	serRS485Enabled      = 1 << 0
+	serRS485RTSOnSend    = 1 << 1
+	serRS485RTSAfterSend = 1 << 2
+	serRS485RxDuringTx   = 1 << 4
+)
+
+// serialRS485 对应内核 struct serial_rs485（TIOCGRS485/TIOCSRS485）
+type serialRS485 struct {
+	Flags              uint32
+	DelayRTSBeforeSend uint32 // 毫秒
+	DelayRTSAfterSend  uint32 // 毫秒
+	Padding            [5]uint32
+}
+
+// enableKernelRS485 让驱动接管 RS-485 方向切换，返回原配置以便关闭时恢复
+func enableKernelRS485(fd int, cfg config.Port) (*serialRS485, error) {
+	prev := &serialRS485{}
+	if err := ioctlPtr(fd, unix.TIOCGRS485, unsafe.Pointer(prev)); err != nil {
+		return nil, fmt.Errorf("TIOCGRS485: %w", err)
+	}
+
+	rs := serialRS485{
+		Flags:              serRS485Enabled,
+		DelayRTSBeforeSend: uint32(cfg.DelayBeforeSendMs),
+		DelayRTSAfterSend:  uint32(cfg.DelayAfterSendMs),
+	}
+	// 发送时 RTS 为高电平，空闲（接收）时为低电平；low 则相反
+	if cfg.RTSOnSend == "low" {
+		rs.Flags |= serRS485RTSAfterSend
+	} else {
+		rs.Flags |= serRS485RTSOnSend
+	}
+	if cfg.RxDuringTx {
+		rs.Flags |= serRS485RxDuringTx
+	}
+	if err := ioctlPtr(fd, unix.TIOCSRS485, unsafe.Pointer(&rs)); err != nil {
+		return nil, fmt.Errorf("TIOCSRS485: %w", err)
+	}
+	return prev, nil
+}
+
+// restoreKernelRS485 恢复打开前的 RS-485 配置
+func restoreKernelRS485(fd int, prev *serialRS485) error {
+	if err := ioctlPtr(fd, unix.TIOCSRS485, unsafe.Pointer(prev)); err != nil {
+		return fmt.Errorf("TIOCSRS485: %w", err)
+	}
+	return nil
+}
@@ -29,7 +29,7 @@ type Port interface {
 	WriteFrame(frame []byte) error
 }
 
-// NewPort 根据配置创建对应的串口实现（UART / RS-485 / RS-232）
+// NewPort 根据配置创建对应的串口实现（UART / RS-485 / RS-232 / 虚拟 pty / 远程 tcp、rfc2217）
 func NewPort(cfg config.Port) (Port, error) {
 	switch cfg.Type {
 	case "uart":
@@ -38,6 +38,12 @@ func NewPort(cfg config.Port) (Port, error) {
 		return NewRS485Port(cfg), nil
 	case "rs232":
 		return NewRS232Port(cfg), nil
+	case "pty":
+		return NewPTYPort(cfg), nil
+	case "tcp":
+		return NewTCPPort(cfg), nil
+	case "rfc2217":
+		return NewRFC2217Port(cfg), nil
 	default:
 		return nil, fmt.Errorf("unknown port type %s", cfg.Type)
 	}
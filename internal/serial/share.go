@@ -0,0 +1,375 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// ErrPortBusy 表示共享端口当前由 TCP 写入客户端独占，MQTT 命令被暂停
+var ErrPortBusy = errors.New("serial port is in use by a share writer")
+
+const (
+	shareQueueLen     = 256             // 每个客户端待发送的数据块上限，积压超过即断开慢客户端
+	shareWriteTimeout = 5 * time.Second // 向客户端写入的超时
+)
+
+// shareClient 是一个已连接的共享客户端，发送在独立协程中进行，不阻塞串口读循环
+type shareClient struct {
+	conn net.Conn
+	out  chan []byte
+	rfc  *rfc2217Session // RFC 2217 客户端的会话，原始 TCP 客户端为 nil
+}
+
+func newShareClient(conn net.Conn) *shareClient {
+	c := &shareClient{conn: conn, out: make(chan []byte, shareQueueLen)}
+	go c.run()
+	return c
+}
+
+// run 把队列中的数据写给客户端，出错时关闭连接
+func (c *shareClient) run() {
+	for p := range c.out {
+		c.conn.SetWriteDeadline(time.Now().Add(shareWriteTimeout))
+		if _, err := c.conn.Write(p); err != nil {
+			c.conn.Close()
+			for range c.out {
+			}
+			return
+		}
+	}
+}
+
+// ShareServer 以 ser2net 的方式在 TCP 上共享一个串口：
+//   - listen：独占写入客户端（如厂商配置工具），同一时刻只接受一个，收到的字节写入串口
+//   - rfc2217Listen：RFC 2217 客户端，与 listen 共用独占写入名额，可远程修改线路参数和 DTR/RTS
+//   - monitorListen：任意数量的只读监视客户端，写入的数据被丢弃
+//
+// 所有客户端都收到串口接收到的原始字节；allow 列表为必填项，只接受列表内地址的客户端。
+// 写入客户端在线期间，MQTT 命令与协议解析按配置暂停（pause）或照常进行（coexist）。
+// nil 表示未配置共享，所有方法直接放行。
+type ShareServer struct {
+	sup   *Supervisor
+	cfg   config.ShareConfig
+	line  LineSettings // 端口配置的线路参数，端口不支持运行时修改时用于应答 RFC 2217 查询
+	modem *ModemHub    // 端口的输入线变化，RFC 2217 会话期间订阅
+	out   PortAccess   // 写入客户端数据与 RFC 2217 线路参数修改的访问路径，默认直接访问监管器
+	allow []*net.IPNet
+
+	lns      []net.Listener
+	onWriter func(addr string, attached bool)
+
+	mu       sync.Mutex
+	writer   *shareClient
+	monitors map[*shareClient]struct{}
+	closed   bool
+}
+
+// NewShareServer 按端口的 share 配置创建共享服务器；未配置时返回 nil。
+// modem 是端口的输入线变化分发器，端口不支持调制解调器线时为 nil
+func NewShareServer(sup *Supervisor, pc config.Port, modem *ModemHub) *ShareServer {
+	if pc.Share == nil {
+		return nil
+	}
+	s := &ShareServer{
+		sup:      sup,
+		cfg:      *pc.Share,
+		line:     lineSettingsOf(pc),
+		modem:    modem,
+		out:      supervisorAccess{sup},
+		monitors: make(map[*shareClient]struct{}),
+	}
+	for _, a := range pc.Share.Allow {
+		// 配置加载时已校验
+		if n, err := config.ParseAllow(a); err == nil {
+			s.allow = append(s.allow, n)
+		}
+	}
+	return s
+}
+
+// SetWriter 设置写入客户端写入数据、RFC 2217 会话修改线路参数的路径，
+// 使其与 MQTT 命令、本地接口事务等其他访问互斥，需在 Start 之前调用
+func (s *ShareServer) SetWriter(w PortAccess) {
+	if s == nil {
+		return
+	}
+	s.out = w
+}
+
+// OnWriter 设置写入客户端接入/断开回调，需在 Start 之前调用
+func (s *ShareServer) OnWriter(fn func(addr string, attached bool)) {
+	if s == nil {
+		return
+	}
+	s.onWriter = fn
+}
+
+// Start 开始监听配置的地址
+func (s *ShareServer) Start() error {
+	if s == nil {
+		return nil
+	}
+	for _, l := range []struct {
+		addr string
+		role string
+	}{{s.cfg.Listen, roleWriter}, {s.cfg.RFC2217Listen, roleRFC2217}, {s.cfg.MonitorListen, roleMonitor}} {
+		if l.addr == "" {
+			continue
+		}
+		ln, err := net.Listen("tcp", l.addr)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("share listen %s: %w", l.addr, err)
+		}
+		s.lns = append(s.lns, ln)
+		fmt.Printf("🔀 [%s] share %s listening on %s\n", s.sup.Name(), l.role, ln.Addr())
+		go s.accept(ln, l.role)
+	}
+	return nil
+}
+
+// 共享客户端角色
+const (
+	roleWriter  = "writer"
+	roleRFC2217 = "rfc2217"
+	roleMonitor = "monitor"
+)
+
+// accept 接受连接直到监听器关闭
+func (s *ShareServer) accept(ln net.Listener, role string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fmt.Printf("⚠️ [%s] share accept failed: %v\n", s.sup.Name(), err)
+			}
+			return
+		}
+		if !s.allowed(conn.RemoteAddr()) {
+			fmt.Printf("⛔ [%s] share %s %s rejected: not in allow list\n", s.sup.Name(), role, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		if role == roleMonitor {
+			s.attachMonitor(conn)
+		} else {
+			s.attachWriter(conn, role == roleRFC2217)
+		}
+	}
+}
+
+// allowed 检查客户端地址是否在允许列表内；列表为空时全部拒绝
+func (s *ShareServer) allowed(addr net.Addr) bool {
+	ta, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range s.allow {
+		if n.Contains(ta.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachWriter 接入独占写入客户端（原始 TCP 或 RFC 2217），已有写入者时拒绝
+func (s *ShareServer) attachWriter(conn net.Conn, rfc bool) {
+	addr := conn.RemoteAddr().String()
+	s.mu.Lock()
+	if s.closed || s.writer != nil {
+		s.mu.Unlock()
+		fmt.Printf("🔀 [%s] share writer %s rejected: port already has a writer\n", s.sup.Name(), addr)
+		conn.Close()
+		return
+	}
+	c := newShareClient(conn)
+	if rfc {
+		c.rfc = newRFC2217Session(s, c)
+	}
+	s.writer = c
+	s.mu.Unlock()
+
+	fmt.Printf("🔀 [%s] share writer %s attached\n", s.sup.Name(), addr)
+	if s.onWriter != nil {
+		s.onWriter(addr, true)
+	}
+	go s.relayWriter(c, addr)
+}
+
+// relayWriter 把写入客户端发来的字节写入串口，连接断开后释放独占；
+// RFC 2217 客户端的数据先经 Telnet 解码，会话结束时恢复被修改的线路参数
+func (s *ShareServer) relayWriter(c *shareClient, addr string) {
+	if c.rfc != nil {
+		c.rfc.start()
+	}
+	buf := make([]byte, 1024)
+	for {
+		n, err := c.conn.Read(buf)
+		if c.rfc != nil {
+			n = c.rfc.codec.decode(buf, buf[:n])
+		}
+		if n > 0 {
+			if _, werr := s.out.Write(buf[:n]); werr != nil {
+				fmt.Printf("⚠️ [%s] share writer %s: write to port failed: %v\n", s.sup.Name(), addr, werr)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if c.rfc != nil {
+		c.rfc.end()
+	}
+	s.mu.Lock()
+	detached := s.writer == c
+	if detached {
+		s.writer = nil
+		close(c.out)
+	}
+	s.mu.Unlock()
+	c.conn.Close()
+	if detached {
+		fmt.Printf("🔀 [%s] share writer %s detached\n", s.sup.Name(), addr)
+		if s.onWriter != nil {
+			s.onWriter(addr, false)
+		}
+	}
+}
+
+// attachMonitor 接入只读监视客户端，其发来的数据被丢弃
+func (s *ShareServer) attachMonitor(conn net.Conn) {
+	addr := conn.RemoteAddr().String()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c := newShareClient(conn)
+	s.monitors[c] = struct{}{}
+	s.mu.Unlock()
+
+	fmt.Printf("🔀 [%s] share monitor %s attached\n", s.sup.Name(), addr)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				break
+			}
+		}
+		s.mu.Lock()
+		if _, ok := s.monitors[c]; ok {
+			delete(s.monitors, c)
+			close(c.out)
+		}
+		s.mu.Unlock()
+		conn.Close()
+		fmt.Printf("🔀 [%s] share monitor %s detached\n", s.sup.Name(), addr)
+	}()
+}
+
+// Broadcast 把串口收到的字节转发给所有客户端；积压过多的客户端被断开
+func (s *ShareServer) Broadcast(p []byte) {
+	if s == nil || len(p) == 0 {
+		return
+	}
+	chunk := append([]byte(nil), p...)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer != nil && !s.enqueue(s.writer, s.writer.encode(chunk)) {
+		// 写入客户端积压时只断开连接，独占在其读协程退出时释放
+		s.writer.conn.Close()
+	}
+	for c := range s.monitors {
+		if !s.enqueue(c, chunk) {
+			delete(s.monitors, c)
+			close(c.out)
+			c.conn.Close()
+		}
+	}
+}
+
+// encode 按客户端协议编码转发数据：RFC 2217 客户端需转义 IAC
+func (c *shareClient) encode(p []byte) []byte {
+	if c.rfc != nil {
+		return escapeIAC(p)
+	}
+	return p
+}
+
+// reply 向写入客户端发送控制应答；客户端已断开时丢弃
+func (s *ShareServer) reply(c *shareClient, p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer == c {
+		s.enqueue(c, p)
+	}
+}
+
+// NotifyBreak 通知 RFC 2217 客户端串口收到了 BREAK
+func (s *ShareServer) NotifyBreak() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	w := s.writer
+	s.mu.Unlock()
+	if w != nil && w.rfc != nil {
+		w.rfc.notifyLine(lsBreak)
+	}
+}
+
+// enqueue 非阻塞地把数据放入客户端队列，调用方需持有 s.mu
+func (s *ShareServer) enqueue(c *shareClient, p []byte) bool {
+	select {
+	case c.out <- p:
+		return true
+	default:
+		fmt.Printf("⚠️ [%s] share client %s too slow, disconnecting\n", s.sup.Name(), c.conn.RemoteAddr())
+		return false
+	}
+}
+
+// writerActive 返回是否有写入客户端在线
+func (s *ShareServer) writerActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writer != nil
+}
+
+// PauseCommands 返回当前是否应暂停 MQTT 命令写入
+func (s *ShareServer) PauseCommands() bool {
+	return s != nil && s.cfg.Commands == config.SharePause && s.writerActive()
+}
+
+// PauseParser 返回当前是否应暂停协议解析（数据仍转发给共享客户端）
+func (s *ShareServer) PauseParser() bool {
+	return s != nil && s.cfg.Parser == config.SharePause && s.writerActive()
+}
+
+// Close 停止监听并断开所有客户端
+func (s *ShareServer) Close() error {
+	if s == nil {
+		return nil
+	}
+	for _, ln := range s.lns {
+		ln.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.writer != nil {
+		s.writer.conn.Close()
+	}
+	for c := range s.monitors {
+		delete(s.monitors, c)
+		close(c.out)
+		c.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package serial
+
+// Counters 是端口的累计计数：收发字节和错误来自 UART 驱动（TIOCGICOUNT），
+// Collisions 来自回显抑制。所有字段按 32 位回绕，差值用 Sub 计算。
+type Counters struct {
+	Rx         uint32 `json:"rx"`
+	Tx         uint32 `json:"tx"`
+	Frame      uint32 `json:"frame"`
+	Parity     uint32 `json:"parity"`
+	Overrun    uint32 `json:"overrun"`    // UART 硬件 FIFO 溢出
+	BufOverrun uint32 `json:"bufOverrun"` // tty 缓冲区溢出
+	Break      uint32 `json:"break"`
+	Collisions uint32 `json:"collisions"`
+}
+
+// CounterReader 由可以读取驱动计数的端口实现
+type CounterReader interface {
+	Counters() (Counters, error)
+}
+
+// Sub 返回 c 相对 prev 的增量
+func (c Counters) Sub(prev Counters) Counters {
+	return Counters{
+		Rx:         c.Rx - prev.Rx,
+		Tx:         c.Tx - prev.Tx,
+		Frame:      c.Frame - prev.Frame,
+		Parity:     c.Parity - prev.Parity,
+		Overrun:    c.Overrun - prev.Overrun,
+		BufOverrun: c.BufOverrun - prev.BufOverrun,
+		Break:      c.Break - prev.Break,
+		Collisions: c.Collisions - prev.Collisions,
+	}
+}
+
+// Errors 返回接收错误总数（帧错误、校验错误、两类溢出）
+func (c Counters) Errors() uint32 {
+	return c.Frame + c.Parity + c.Overrun + c.BufOverrun
+}
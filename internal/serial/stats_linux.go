@@ -0,0 +1,36 @@
+package serial
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// serialICounter 对应内核 struct serial_icounter_struct
+type serialICounter struct {
+	CTS, DSR, RNG, DCD int32
+	Rx, Tx             int32
+	Frame, Overrun     int32
+	Parity, Brk        int32
+	BufOverrun         int32
+	Reserved           [9]int32
+}
+
+// readCounters 通过 TIOCGICOUNT 读取驱动计数
+func readCounters(fd uintptr, echo *echoCanceller) (Counters, error) {
+	var ic serialICounter
+	if err := ioctlPtr(int(fd), unix.TIOCGICOUNT, unsafe.Pointer(&ic)); err != nil {
+		return Counters{}, fmt.Errorf("TIOCGICOUNT: %w", err)
+	}
+	return Counters{
+		Rx:         uint32(ic.Rx),
+		Tx:         uint32(ic.Tx),
+		Frame:      uint32(ic.Frame),
+		Parity:     uint32(ic.Parity),
+		Overrun:    uint32(ic.Overrun),
+		BufOverrun: uint32(ic.BufOverrun),
+		Break:      uint32(ic.Brk),
+		Collisions: uint32(echo.Collisions()),
+	}, nil
+}
@@ -0,0 +1,312 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrPortDown 表示端口当前处于断开状态，正在等待重新打开
+	ErrPortDown = errors.New("serial port is disconnected")
+	// ErrSupervisorClosed 表示端口监管已停止
+	ErrSupervisorClosed = errors.New("serial port supervisor closed")
+)
+
+// Prober 由可以探测底层设备是否仍然存在的端口实现，
+// 用于区分读到 io.EOF 时设备是否已被拔出
+type Prober interface {
+	Probe() error
+}
+
+// IsFatal 判断错误是否意味着设备已不可用（拔出、被移除、句柄失效或网络连接中断），需要关闭后重新打开
+func IsFatal(err error) bool {
+	return errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.ENXIO) ||
+		errors.Is(err, syscall.ENODEV) ||
+		errors.Is(err, syscall.EBADF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ETIMEDOUT) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, os.ErrClosed)
+}
+
+// IsAbsent 判断打开失败是否因为设备暂不可用（节点不存在、match 无结果、串口服务器连不上
+// 或被其他进程锁定），此类端口可先进入等待状态，由热插拔事件或退避重试在设备可用后打开
+func IsAbsent(err error) bool {
+	return errors.Is(err, os.ErrNotExist) ||
+		errors.Is(err, syscall.ENODEV) ||
+		errors.Is(err, syscall.ENXIO) ||
+		errors.Is(err, ErrNoDevice) ||
+		errors.Is(err, ErrRemoteUnavailable) ||
+		errors.Is(err, ErrPortLocked)
+}
+
+// Supervisor 监管一个端口的生命周期：
+// 读写遇到致命错误时关闭端口、上报断开，随后按指数退避重新打开
+// （Open 会按配置重新应用全部线路参数），成功后上报连接。
+type Supervisor struct {
+	port       Port
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onState    func(connected bool, err error)
+
+	mu        sync.Mutex
+	connected bool
+	closed    bool
+	changed   chan struct{} // 连接状态变化或停止监管时关闭并替换
+	kick      chan struct{} // 热插拔通知，唤醒退避等待立即重试
+	done      chan struct{} // 停止监管时关闭
+}
+
+// NewSupervisor 创建端口监管器，重连间隔在 [minBackoff, maxBackoff] 内指数增长
+func NewSupervisor(p Port, minBackoff, maxBackoff time.Duration) *Supervisor {
+	return &Supervisor{
+		port:       p,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		changed:    make(chan struct{}),
+		kick:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+// OnState 设置连接状态变化回调，需在 Start 之前调用
+func (s *Supervisor) OnState(fn func(connected bool, err error)) {
+	s.onState = fn
+}
+
+// Start 首次打开端口
+func (s *Supervisor) Start() error {
+	if err := s.port.Open(); err != nil {
+		return err
+	}
+	s.setConnected()
+	return nil
+}
+
+// Await 在首次打开失败（如适配器未插入）后进入断开状态，由后台按退避重试，
+// 热插拔事件通过 Plugged 唤醒立即打开
+func (s *Supervisor) Await(err error) {
+	fmt.Printf("⏳ [%s] waiting for device: %v\n", s.Name(), err)
+	s.emit(false, err)
+	go s.reconnect()
+}
+
+// Plugged 通知可能匹配的设备已出现：断开状态下跳过剩余退避立即尝试打开
+func (s *Supervisor) Plugged() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Unplugged 通知有设备被移除：已连接且探测确认设备失效时关闭端口并进入重连等待
+func (s *Supervisor) Unplugged() {
+	if !s.Connected() {
+		return
+	}
+	pr, ok := s.port.(Prober)
+	if !ok {
+		return
+	}
+	if err := pr.Probe(); err != nil {
+		s.fail(fmt.Errorf("device removed: %w", err))
+	}
+}
+
+// Port 返回被监管的端口，用于访问 ModemController 等可选能力
+func (s *Supervisor) Port() Port {
+	return s.port
+}
+
+// Name 返回逻辑端口名称
+func (s *Supervisor) Name() string {
+	return s.port.Name()
+}
+
+// Connected 返回端口当前是否可用
+func (s *Supervisor) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// Read 从端口读取；端口断开期间阻塞等待重连，停止监管后返回 ErrSupervisorClosed
+func (s *Supervisor) Read(p []byte) (int, error) {
+	for {
+		if err := s.waitConnected(); err != nil {
+			return 0, err
+		}
+		n, err := s.port.Read(p)
+		if err != nil && s.fatal(err) {
+			s.fail(err)
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Write 向端口写入；端口断开时立即返回 ErrPortDown
+func (s *Supervisor) Write(p []byte) (int, error) {
+	if !s.Connected() {
+		return 0, ErrPortDown
+	}
+	n, err := s.port.Write(p)
+	if err != nil && s.fatal(err) {
+		s.fail(err)
+	}
+	return n, err
+}
+
+// Close 停止监管并关闭端口
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	wasConnected := s.connected
+	s.connected = false
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	if !wasConnected {
+		return nil
+	}
+	err := s.port.Close()
+	s.emit(false, ErrSupervisorClosed)
+	return err
+}
+
+// Done 返回停止监管时关闭的通道，跨越重连持续运行的后台任务据此退出
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// fatal 判断错误是否需要重连；读到的 io.EOF 通过 Probe 确认设备是否仍在
+func (s *Supervisor) fatal(err error) bool {
+	if IsFatal(err) {
+		return true
+	}
+	if errors.Is(err, io.EOF) {
+		if pr, ok := s.port.(Prober); ok {
+			return pr.Probe() != nil
+		}
+	}
+	return false
+}
+
+// waitConnected 阻塞直到端口可用或停止监管
+func (s *Supervisor) waitConnected() error {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrSupervisorClosed
+		}
+		if s.connected {
+			s.mu.Unlock()
+			return nil
+		}
+		ch := s.changed
+		s.mu.Unlock()
+		<-ch
+	}
+}
+
+// setConnected 标记端口已连接并通知等待者和回调
+func (s *Supervisor) setConnected() {
+	s.mu.Lock()
+	s.connected = true
+	s.notifyLocked()
+	s.mu.Unlock()
+	s.emit(true, nil)
+}
+
+// notifyLocked 唤醒所有等待状态变化的协程，调用方需持有 s.mu
+func (s *Supervisor) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// emit 调用状态回调
+func (s *Supervisor) emit(connected bool, err error) {
+	if s.onState != nil {
+		s.onState(connected, err)
+	}
+}
+
+// fail 关闭失效的端口并启动重连；并发调用时只处理一次
+func (s *Supervisor) fail(err error) {
+	s.mu.Lock()
+	if !s.connected || s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.connected = false
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	fmt.Printf("🔌 [%s] port lost: %v\n", s.Name(), err)
+	s.port.Close()
+	s.emit(false, err)
+	go s.reconnect()
+}
+
+// reconnect 按指数退避反复尝试重新打开端口，Plugged 可提前唤醒
+func (s *Supervisor) reconnect() {
+	backoff := s.minBackoff
+	// 丢弃连接期间残留的通知
+	select {
+	case <-s.kick:
+	default:
+	}
+	for {
+		s.mu.Lock()
+		closed := s.closed
+		changed := s.changed
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-changed:
+			// 停止监管
+			timer.Stop()
+			continue
+		case <-s.kick:
+			// 设备刚出现，立即重试并从最小间隔重新退避（udev 可能仍在设置权限）
+			timer.Stop()
+			backoff = s.minBackoff
+		}
+		err := s.port.Open()
+		if err == nil {
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				s.port.Close()
+				return
+			}
+			s.mu.Unlock()
+			fmt.Printf("🔌 [%s] port reopened\n", s.Name())
+			s.setConnected()
+			return
+		}
+		fmt.Printf("🔌 [%s] reopen failed, retry in %v: %v\n", s.Name(), backoff, err)
+		backoff = min(backoff*2, s.maxBackoff)
+	}
+}
@@ -0,0 +1,154 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// ErrRemoteUnavailable 表示串口服务器暂时连不上，端口进入等待并按退避重连
+var ErrRemoteUnavailable = errors.New("remote serial server unavailable")
+
+// errRemoteClosed 表示串口服务器关闭了连接
+var errRemoteClosed = errors.New("remote serial server closed the connection")
+
+const (
+	tcpDialTimeout = 5 * time.Second
+	tcpKeepAlive   = 15 * time.Second // 及时发现断电/断网的串口服务器
+)
+
+// TCPPort 连接工作在原始 TCP 模式（ser2net raw、Moxa/USR TCP Server）的串口服务器，
+// 连接上的字节流即串口数据；线路参数由串口服务器自身配置。
+// 断线后由 Supervisor 按退避重新连接。
+type TCPPort struct {
+	cfg    config.Port
+	mu     sync.Mutex // 保护 conn：监管器重新连接时替换，读循环与各写入路径并发取快照
+	conn   net.Conn
+	closed atomic.Bool // 对端已关闭连接
+}
+
+// NewTCPPort 根据配置返回 TCPPort 实例
+func NewTCPPort(cfg config.Port) Port {
+	return &TCPPort{cfg: cfg}
+}
+
+// dialRemote 连接串口服务器；只有服务器暂时连不上时才包装为 ErrRemoteUnavailable，
+// 地址写错、域名解析失败等配置错误原样返回
+func dialRemote(addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: tcpDialTimeout, KeepAlive: tcpKeepAlive}
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		if remoteUnreachable(err) {
+			return nil, fmt.Errorf("%w: %w", ErrRemoteUnavailable, err)
+		}
+		return nil, err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		// 小报文（如 Modbus 请求）立即发出
+		tc.SetNoDelay(true)
+	}
+	return conn, nil
+}
+
+// remoteUnreachable 判断连接失败是否因为服务器未启动、掉电或网络暂时不通
+func remoteUnreachable(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
+
+// Open 连接串口服务器
+func (t *TCPPort) Open() error {
+	conn, err := dialRemote(t.cfg.Address)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.conn = conn
+	t.mu.Unlock()
+	t.closed.Store(false)
+	fmt.Printf("🌐 [%s] connected to %s\n", t.cfg.Name, t.cfg.Address)
+	return nil
+}
+
+// current 返回当前连接
+func (t *TCPPort) current() net.Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conn
+}
+
+// Close 断开连接
+func (t *TCPPort) Close() error {
+	if c := t.current(); c != nil {
+		return c.Close()
+	}
+	return nil
+}
+
+// Read 从连接读取串口数据；对端关闭时返回 io.EOF，并由 Probe 报告连接已失效
+func (t *TCPPort) Read(p []byte) (int, error) {
+	c := t.current()
+	if c == nil {
+		return 0, net.ErrClosed
+	}
+	n, err := c.Read(p)
+	if errors.Is(err, io.EOF) {
+		t.closed.Store(true)
+		return n, fmt.Errorf("%w: %w", errRemoteClosed, err)
+	}
+	return n, err
+}
+
+// Write 写入串口数据
+func (t *TCPPort) Write(p []byte) (int, error) {
+	c := t.current()
+	if c == nil {
+		return 0, fmt.Errorf("tcp write failed: %w", net.ErrClosed)
+	}
+	n, err := c.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("tcp write failed: %w", err)
+	}
+	return n, nil
+}
+
+// Name 返回逻辑名称
+func (t *TCPPort) Name() string {
+	return t.cfg.Name
+}
+
+// ReadFrame 与 UART 一样把一次 Read 当作一帧
+func (t *TCPPort) ReadFrame() ([]byte, error) {
+	buf := make([]byte, 4096)
+	n, err := t.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// WriteFrame 写整帧
+func (t *TCPPort) WriteFrame(frame []byte) error {
+	_, err := t.Write(frame)
+	return err
+}
+
+// Probe 报告连接是否已被对端关闭
+func (t *TCPPort) Probe() error {
+	if t.closed.Load() {
+		return errRemoteClosed
+	}
+	return nil
+}
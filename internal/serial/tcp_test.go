@@ -0,0 +1,30 @@
+package serial
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestDialRemoteRefusedIsAbsent(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = dialRemote(addr)
+	if !errors.Is(err, ErrRemoteUnavailable) || !IsAbsent(err) {
+		t.Fatalf("dial closed port error = %v, want ErrRemoteUnavailable", err)
+	}
+}
+
+func TestDialRemoteBadAddressIsNotAbsent(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "127.0.0.1:port", "[::1:4001"} {
+		_, err := dialRemote(addr)
+		if err == nil || IsAbsent(err) {
+			t.Fatalf("dial %q error = %v, want a configuration error", addr, err)
+		}
+	}
+}
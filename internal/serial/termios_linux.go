@@ -0,0 +1,329 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// asyncLowLatency 对应内核 serial_struct.flags 中的 ASYNC_LOW_LATENCY
+const asyncLowLatency = 1 << 13
+
+// serialStruct 对应内核 struct serial_struct（TIOCGSERIAL/TIOCSSERIAL）
+type serialStruct struct {
+	Type          int32
+	Line          int32
+	Port          uint32
+	Irq           int32
+	Flags         int32
+	XmitFifoSize  int32
+	CustomDivisor int32
+	BaudBase      int32
+	CloseDelay    uint16
+	IoType        uint8
+	ReservedChar  [1]uint8
+	Hub6          int32
+	ClosingWait   uint16
+	ClosingWait2  uint16
+	IomemBase     uintptr
+	IomemRegShift uint16
+	PortHigh      uint32
+	IomapBase     uintptr
+}
+
+// pollTTY 是以 O_NONBLOCK 打开的 tty，读写由 Go 运行时轮询器（epoll）驱动：
+// 数据到达即返回，空闲时不占用线程，Close 立即打断阻塞中的 Read。
+// ioctl 经 RawConn.Control 取得描述符：调用 os.File.Fd 会把文件切回阻塞模式
+type pollTTY struct {
+	*os.File
+	rc syscall.RawConn
+}
+
+// openPollTTY 以非阻塞方式打开 tty 并交给运行时轮询器
+func openPollTTY(dev string) (*pollTTY, error) {
+	f, err := os.OpenFile(dev, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &pollTTY{File: f, rc: rc}, nil
+}
+
+// Fd 返回当前描述符，不改变文件的非阻塞模式；
+// 关闭后与 os.File.Fd 一样返回 ^uintptr(0)，之后的 ioctl 得到 EBADF，而不是作用到被复用的描述符上
+func (p *pollTTY) Fd() uintptr {
+	fd := ^uintptr(0)
+	if err := p.rc.Control(func(x uintptr) { fd = x }); err != nil {
+		return ^uintptr(0)
+	}
+	return fd
+}
+
+// termiosPort 是直接基于 termios2 的串口后端：
+// 支持任意波特率（BOTHER）、ASYNC_LOW_LATENCY 与 TIOCEXCL；
+// 读取由轮询器驱动，minRead/interCharTimeoutMs 在用户态按 VMIN/VTIME 语义实现
+type termiosPort struct {
+	*pollTTY
+	vmin      atomic.Int64 // 一次读取至少返回的字节数
+	interChar atomic.Int64 // 读到数据后线路空闲超过该时间即返回，0 表示不等待
+}
+
+// openTermios 打开设备并以原始模式配置全部线路参数
+func openTermios(cfg config.Port) (*termiosPort, error) {
+	f, err := openPollTTY(cfg.Device)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureTermios(int(f.Fd()), cfg); err != nil {
+		f.Close()
+		return nil, err
+	}
+	t := &termiosPort{pollTTY: f}
+	t.setReadAggregation(readAggregation(cfg))
+	return t, nil
+}
+
+// setReadAggregation 修改读取聚合方式，可与 Read 并发调用，对下一次 Read 生效
+func (t *termiosPort) setReadAggregation(vmin int, interChar time.Duration) {
+	t.vmin.Store(int64(vmin))
+	t.interChar.Store(int64(interChar))
+}
+
+// configureTermios 依次设置独占、termios2、低延迟；描述符保持非阻塞
+func configureTermios(fd int, cfg config.Port) error {
+	if cfg.Exclusive {
+		if err := unix.IoctlSetInt(fd, unix.TIOCEXCL, 0); err != nil {
+			return fmt.Errorf("TIOCEXCL: %w", err)
+		}
+	}
+
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS2)
+	if err != nil {
+		return fmt.Errorf("TCGETS2: %w", err)
+	}
+	makeRaw(t)
+	// BOTHER：直接使用 c_ispeed/c_ospeed 中的波特率数值
+	if cfg.Baudrate > 0 {
+		// 未填波特率（伪终端）时保留当前速率，B0 会让 tty 挂断
+		t.Cflag &^= unix.CBAUD | unix.CIBAUD
+		t.Cflag |= unix.BOTHER | unix.BOTHER<<unix.IBSHIFT
+		t.Ispeed = uint32(cfg.Baudrate)
+		t.Ospeed = uint32(cfg.Baudrate)
+	}
+	if err := applyLineSettings(t, cfg); err != nil {
+		return err
+	}
+	// 非阻塞读取不受 VMIN/VTIME 影响，按阻塞读取的默认值设置，供其他打开者使用
+	t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS2, t); err != nil {
+		return fmt.Errorf("TCSETS2: %w", err)
+	}
+
+	if cfg.LowLatency {
+		if err := setLowLatency(fd); err != nil {
+			return fmt.Errorf("set low latency: %w", err)
+		}
+	}
+	return nil
+}
+
+// makeRaw 关闭所有行规程处理，等价于 cfmakeraw
+func makeRaw(t *unix.Termios) {
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP |
+		unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag |= unix.CREAD | unix.CLOCAL
+}
+
+// readAggregation 由配置推算读取的聚合方式（与内核 VMIN/VTIME 语义一致）：
+//   - interCharTimeoutMs > 0：读到 max(minRead,1) 字节即返回，之前字符间隔超时也返回
+//   - 仅 minRead > 0：等到读满 minRead 字节
+//   - 否则有数据即返回
+func readAggregation(cfg config.Port) (int, time.Duration) {
+	return max(cfg.MinRead, 1), time.Duration(cfg.InterCharTimeoutMs) * time.Millisecond
+}
+
+// setLowLatency 打开 ASYNC_LOW_LATENCY，让驱动尽快把收到的数据交给 tty 层
+func setLowLatency(fd int) error {
+	var ss serialStruct
+	if err := ioctlPtr(fd, unix.TIOCGSERIAL, unsafe.Pointer(&ss)); err != nil {
+		return fmt.Errorf("TIOCGSERIAL: %w", err)
+	}
+	ss.Flags |= asyncLowLatency
+	if err := ioctlPtr(fd, unix.TIOCSSERIAL, unsafe.Pointer(&ss)); err != nil {
+		return fmt.Errorf("TIOCSSERIAL: %w", err)
+	}
+	return nil
+}
+
+// ioctlPtr 以指针参数调用 ioctl
+func ioctlPtr(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Read 在轮询器中等待数据，按 minRead/interCharTimeoutMs 聚合后返回
+func (t *termiosPort) Read(p []byte) (int, error) {
+	n, err := t.File.Read(p)
+	want := min(int(t.vmin.Load()), len(p))
+	if err != nil || n >= want {
+		return n, err
+	}
+	interChar := time.Duration(t.interChar.Load())
+	defer t.File.SetReadDeadline(time.Time{})
+	for n < want {
+		if interChar > 0 {
+			t.File.SetReadDeadline(time.Now().Add(interChar))
+		}
+		m, err := t.File.Read(p[n:])
+		n += m
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			// 字符间隔超时，返回已读到的数据
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// setLine 在运行时修改波特率（BOTHER，两种后端通用）与数据位/校验/停止位/流控
+func setLine(fd int, cfg config.Port) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS2)
+	if err != nil {
+		return fmt.Errorf("TCGETS2: %w", err)
+	}
+	if cfg.Baudrate > 0 {
+		// 未填波特率（伪终端）时保留当前速率，B0 会让 tty 挂断
+		t.Cflag &^= unix.CBAUD | unix.CIBAUD
+		t.Cflag |= unix.BOTHER | unix.BOTHER<<unix.IBSHIFT
+		t.Ispeed = uint32(cfg.Baudrate)
+		t.Ospeed = uint32(cfg.Baudrate)
+	}
+	if err := applyLineSettings(t, cfg); err != nil {
+		return err
+	}
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS2, t); err != nil {
+		return fmt.Errorf("TCSETS2: %w", err)
+	}
+	return nil
+}
+
+// setLineSettings 读取当前 termios，写入数据位/校验/停止位/流控后再设置回去
+func setLineSettings(fd int, cfg config.Port) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("TCGETS: %w", err)
+	}
+	if err := applyLineSettings(t, cfg); err != nil {
+		return err
+	}
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, t); err != nil {
+		return fmt.Errorf("TCSETS: %w", err)
+	}
+	return nil
+}
+
+// applyLineSettings 把数据位、校验、停止位和流控写入 termios 结构
+func applyLineSettings(t *unix.Termios, cfg config.Port) error {
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CMSPAR | unix.CSTOPB | unix.CRTSCTS
+	t.Iflag &^= unix.INPCK | unix.ISTRIP | unix.IXON | unix.IXOFF | unix.IXANY | unix.PARMRK
+
+	if cfg.DetectBreak {
+		// BREAK 以 0xFF 0x00 0x00 标记上报，数据中的 0xFF 转义为 0xFF 0xFF
+		t.Iflag &^= unix.IGNBRK | unix.BRKINT
+		t.Iflag |= unix.PARMRK
+	}
+
+	switch cfg.DataBits {
+	case 5:
+		t.Cflag |= unix.CS5
+	case 6:
+		t.Cflag |= unix.CS6
+	case 7:
+		t.Cflag |= unix.CS7
+	case 8:
+		t.Cflag |= unix.CS8
+	default:
+		return fmt.Errorf("unsupported dataBits %d", cfg.DataBits)
+	}
+
+	switch cfg.Parity {
+	case config.ParityNone:
+	case config.ParityOdd:
+		t.Cflag |= unix.PARENB | unix.PARODD
+	case config.ParityEven:
+		t.Cflag |= unix.PARENB
+	case config.ParityMark:
+		t.Cflag |= unix.PARENB | unix.CMSPAR | unix.PARODD
+	case config.ParitySpace:
+		t.Cflag |= unix.PARENB | unix.CMSPAR
+	default:
+		return fmt.Errorf("unsupported parity %q", cfg.Parity)
+	}
+	if cfg.Parity != config.ParityNone {
+		// 开启输入校验，校验错误的字节直接丢弃（IGNPAR）
+		t.Iflag |= unix.INPCK | unix.IGNPAR
+	}
+
+	// 1.5 与 2 停止位都对应 CSTOPB，由数据位决定实际含义
+	if cfg.StopBits > 1 {
+		t.Cflag |= unix.CSTOPB
+	}
+
+	switch cfg.FlowControl {
+	case config.FlowNone:
+	case config.FlowRTSCTS:
+		t.Cflag |= unix.CRTSCTS
+	case config.FlowXONXOFF:
+		t.Iflag |= unix.IXON | unix.IXOFF
+		t.Cc[unix.VSTART] = 0x11
+		t.Cc[unix.VSTOP] = 0x13
+	default:
+		return fmt.Errorf("unsupported flowControl %q", cfg.FlowControl)
+	}
+	return nil
+}
+
+// drainTx 等待发送完成：先 tcdrain 等内核缓冲区清空，
+// 再轮询 TIOCSERGETLSR 直到移位寄存器为空，保证最后一个字节的停止位也已发出。
+// 驱动不支持 TIOCSERGETLSR（多数 USB 串口）时只依赖 tcdrain。
+func drainTx(fd int, charTime time.Duration) error {
+	if err := unix.IoctlSetInt(fd, unix.TCSBRK, 1); err != nil {
+		return fmt.Errorf("tcdrain: %w", err)
+	}
+	// 移位寄存器里至多还有一个字符，留足几个字符时间的余量
+	deadline := time.Now().Add(4*charTime + time.Millisecond)
+	for {
+		lsr, err := unix.IoctlGetInt(fd, unix.TIOCSERGETLSR)
+		if err != nil || lsr&unix.TIOCSER_TEMT != 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("transmitter not empty after drain")
+		}
+		time.Sleep(charTime / 4)
+	}
+}
+
+// probeTTY 检查 tty 是否仍然可用：设备被拔出后句柄处于挂断状态，TCGETS 返回 EIO
+func probeTTY(fd uintptr) error {
+	_, err := unix.IoctlGetTermios(int(fd), unix.TCGETS)
+	return err
+}
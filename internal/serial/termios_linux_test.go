@@ -0,0 +1,129 @@
+package serial
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+// openTermiosPair 在伪终端从端上以 termios 后端打开端口，返回主端作为对端
+func openTermiosPair(tb testing.TB, minRead, interCharMs int) (*termiosPort, *os.File) {
+	tb.Helper()
+	master, slave, path, err := openPTY()
+	if err != nil {
+		tb.Skipf("pty unavailable: %v", err)
+	}
+	tb.Cleanup(func() {
+		slave.Close()
+		master.Close()
+	})
+	p, err := openTermios(config.Port{
+		Name: "T", Device: path, Baudrate: 115200, DataBits: 8, Parity: config.ParityNone, StopBits: 1,
+		FlowControl: config.FlowNone, MinRead: minRead, InterCharTimeoutMs: interCharMs,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { p.Close() })
+	return p, master
+}
+
+func TestPollTTYFdAfterClose(t *testing.T) {
+	p, _ := openTermiosPair(t, 0, 0)
+	if _, err := unix.IoctlGetTermios(int(p.Fd()), unix.TCGETS2); err != nil {
+		t.Fatalf("ioctl on open port: %v", err)
+	}
+	p.Close()
+	if fd := p.Fd(); fd != ^uintptr(0) {
+		t.Fatalf("Fd after Close = %d, want ^uintptr(0)", fd)
+	}
+	if _, err := unix.IoctlGetTermios(int(p.Fd()), unix.TCGETS2); !errors.Is(err, unix.EBADF) {
+		t.Fatalf("ioctl after Close error = %v, want EBADF", err)
+	}
+}
+
+func TestTermiosCloseUnblocksRead(t *testing.T) {
+	p, _ := openTermiosPair(t, 0, 0)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Read(make([]byte, 16))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Read returned nil error after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not unblock Read")
+	}
+}
+
+func TestTermiosReadAggregation(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		minRead     int
+		interCharMs int
+		want        int
+	}{
+		// minRead 为 1 时有数据即返回，interCharTimeoutMs 不起作用
+		{"first chunk", 1, 50, 3},
+		// 读满 minRead 即返回
+		{"minRead", 5, 0, 5},
+		// 读满 minRead 前线路空闲超时，返回已读到的数据
+		{"interChar timeout", 8, 50, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, master := openTermiosPair(t, tc.minRead, tc.interCharMs)
+			done := make(chan []byte, 1)
+			go func() {
+				buf := make([]byte, 16)
+				n, _ := p.Read(buf)
+				done <- buf[:n]
+			}()
+			master.Write([]byte{1, 2, 3})
+			time.Sleep(20 * time.Millisecond)
+			master.Write([]byte{4, 5})
+			select {
+			case got := <-done:
+				if len(got) != tc.want {
+					t.Fatalf("read %d bytes % X, want %d", len(got), got, tc.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Read did not return")
+			}
+		})
+	}
+}
+
+// BenchmarkTermiosReadLatency 测量对端写入到 Read 返回的时间（线路参数 115200 8N1），
+// 每次操作即一次往返；伪终端不按波特率限速，结果只包含软件路径的唤醒延迟
+func BenchmarkTermiosReadLatency(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		size int
+	}{{"byte", 1}, {"frame32", 32}} {
+		b.Run(bc.name, func(b *testing.B) {
+			size := bc.size
+			p, master := openTermiosPair(b, size, 0)
+			frame := bytes.Repeat([]byte{0x55}, size)
+			buf := make([]byte, 256)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := master.Write(frame); err != nil {
+					b.Fatal(err)
+				}
+				if n, err := p.Read(buf); err != nil || n != size {
+					b.Fatalf("read %d bytes: %v", n, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package serial
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// FrameTiming 是一帧首/尾字节的到达时刻（含单调时钟读数）与帧内最大字节间隔
+type FrameTiming struct {
+	First  time.Time
+	Last   time.Time
+	MaxGap time.Duration
+}
+
+// rxChunk 是读缓存中一次 Read 得到的字节块
+type rxChunk struct {
+	n  int
+	at time.Time // Read 返回的时刻，即块中最后一个字节的到达时刻
+}
+
+// RxTimeline 与读循环的解析缓存一一对应，记录每个读取块的到达时刻：
+// 块内各字节按字符时间向前推算，帧的首/尾字节时刻和帧内字节间隔由此得出
+type RxTimeline struct {
+	port   Port
+	serial bool          // 物理串口才按波特率推算块内字节时刻，pty/网络端口的数据整块到达
+	char   time.Duration // 单字符传输时间，每开始累积新的一帧时按端口当前参数刷新
+	chunks []rxChunk
+	size   int
+	gaps   *GapStats
+}
+
+// NewRxTimeline 为端口创建读缓存时间线
+func NewRxTimeline(p Port, cfg config.Port) *RxTimeline {
+	t := &RxTimeline{port: p, gaps: &GapStats{}}
+	switch cfg.Type {
+	case "uart", "rs485", "rs232":
+		t.serial = true
+		t.char = charTime(cfg)
+	}
+	return t
+}
+
+// Gaps 返回帧内字节间隔统计
+func (t *RxTimeline) Gaps() *GapStats {
+	return t.gaps
+}
+
+// Add 记录追加到缓存末尾的 n 个字节及其 Read 返回时刻
+func (t *RxTimeline) Add(n int, at time.Time) {
+	if n <= 0 {
+		return
+	}
+	if t.size == 0 && t.serial {
+		// 线路参数可能在运行时被修改（自动检测、RFC 2217 等），按当前参数刷新字符时间
+		if lc, ok := t.port.(LineConfigurer); ok {
+			if ls := lc.LineSettings(); ls.Baudrate > 0 {
+				t.char = charTime(config.Port{Baudrate: ls.Baudrate, DataBits: ls.DataBits, Parity: ls.Parity, StopBits: ls.StopBits})
+			}
+		}
+	}
+	t.chunks = append(t.chunks, rxChunk{n: n, at: at})
+	t.size += n
+}
+
+// byteTime 推算第 ci 块中偏移 off 处字节的到达时刻：块内字节按字符时间均匀到达，且不早于上一块
+func (t *RxTimeline) byteTime(ci, off int) time.Time {
+	c := t.chunks[ci]
+	at := c.at.Add(-time.Duration(c.n-1-off) * t.char)
+	if ci > 0 && at.Before(t.chunks[ci-1].at) {
+		at = t.chunks[ci-1].at
+	}
+	return at
+}
+
+// locate 返回第 i 个字节所在的块及块内偏移
+func (t *RxTimeline) locate(i int) (int, int) {
+	for ci, c := range t.chunks {
+		if i < c.n {
+			return ci, i
+		}
+		i -= c.n
+	}
+	last := len(t.chunks) - 1
+	return last, t.chunks[last].n - 1
+}
+
+// Span 返回缓存中 [start,end) 字节的首/尾字节时刻和其中最大的块间空闲，并计入间隔统计
+func (t *RxTimeline) Span(start, end int) FrameTiming {
+	if len(t.chunks) == 0 || end <= start {
+		now := time.Now()
+		return FrameTiming{First: now, Last: now}
+	}
+	fc, fo := t.locate(start)
+	lc, lo := t.locate(end - 1)
+	ft := FrameTiming{First: t.byteTime(fc, fo), Last: t.byteTime(lc, lo)}
+	for ci := fc + 1; ci <= lc; ci++ {
+		// 上一块最后一个字节到本块第一个字节之间线路空闲的时间
+		gap := t.byteTime(ci, 0).Sub(t.chunks[ci-1].at)
+		t.gaps.record(gap)
+		ft.MaxGap = max(ft.MaxGap, gap)
+	}
+	return ft
+}
+
+// Consume 丢弃缓存开头的 k 个字节（已成帧的数据）
+func (t *RxTimeline) Consume(k int) {
+	for k > 0 && len(t.chunks) > 0 {
+		c := &t.chunks[0]
+		if k < c.n {
+			c.n -= k
+			t.size -= k
+			return
+		}
+		k -= c.n
+		t.size -= c.n
+		t.chunks = t.chunks[1:]
+	}
+	if len(t.chunks) == 0 {
+		t.Reset()
+	}
+}
+
+// Reset 清空时间线，与解析缓存被丢弃时同步调用
+func (t *RxTimeline) Reset() {
+	t.chunks = t.chunks[:0]
+	t.size = 0
+}
+
+// FrameOffset 返回帧在已消费区域中的起始位置：解析器跳过的帧头前垃圾不计入帧首时刻。
+// 帧为原始字节或 ASCII 十六进制文本时都能定位，找不到时视为从区域开头开始
+func FrameOffset(region, frame []byte) int {
+	if len(frame) == 0 {
+		return 0
+	}
+	if i := bytes.LastIndex(region, frame); i >= 0 {
+		return i
+	}
+	enc := []byte(hex.EncodeToString(frame))
+	if i := bytes.LastIndex(region, bytes.ToUpper(enc)); i >= 0 {
+		return i
+	}
+	if i := bytes.LastIndex(region, enc); i >= 0 {
+		return i
+	}
+	return 0
+}
+
+// GapStats 累计帧内字节间隔（块间线路空闲时间），由读循环写入、上报协程读取
+type GapStats struct {
+	mu    sync.Mutex
+	count int
+	min   time.Duration
+	max   time.Duration
+	sum   time.Duration
+}
+
+// GapSnapshot 是一个统计区间内的字节间隔
+type GapSnapshot struct {
+	Count  int   `json:"count"`
+	MinUs  int64 `json:"minUs"`
+	MaxUs  int64 `json:"maxUs"`
+	MeanUs int64 `json:"meanUs"`
+}
+
+// record 计入一个间隔
+func (g *GapStats) record(d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.count == 0 || d < g.min {
+		g.min = d
+	}
+	g.max = max(g.max, d)
+	g.sum += d
+	g.count++
+}
+
+// Snapshot 返回自上次调用以来的统计并清零
+func (g *GapStats) Snapshot() GapSnapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := GapSnapshot{Count: g.count}
+	if g.count > 0 {
+		s.MinUs = g.min.Microseconds()
+		s.MaxUs = g.max.Microseconds()
+		s.MeanUs = (g.sum / time.Duration(g.count)).Microseconds()
+	}
+	g.count, g.min, g.max, g.sum = 0, 0, 0, 0
+	return s
+}
@@ -2,49 +2,65 @@ package serial
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/linjuya-lu/device_uart_go/internal/config"
-	"github.com/tarm/serial"
 )
 
 // 标准 UART 全双工串口操作
 type UARTPort struct {
+	name   string     // 逻辑名称，构造后不变，读取无需加锁
+	lmu    sync.Mutex // 保护 cfg 与 handle：监管器重新打开时替换句柄，线路参数可能被并发修改
 	cfg    config.Port
-	handle *serial.Port
+	handle rawPort
+	echo   *echoCanceller // 回显抑制，未启用时为 nil
+	brk    *breakDecoder  // BREAK 检测，未启用时为 nil
 }
 
 // 根据配置返回 UARTPort 实例
 func NewUARTPort(cfg config.Port) Port {
-	return &UARTPort{cfg: cfg}
+	return &UARTPort{name: cfg.Name, cfg: cfg, echo: newEchoCanceller(cfg), brk: newBreakDecoder(cfg.DetectBreak)}
 }
 
 // Open 打开并配置串口设备
 func (u *UARTPort) Open() error {
-	sc := &serial.Config{
-		Name:        u.cfg.Device,
-		Baud:        u.cfg.Baudrate,
-		ReadTimeout: time.Duration(u.cfg.TimeoutMs) * time.Millisecond,
-	}
-	p, err := serial.OpenPort(sc)
+	u.lmu.Lock()
+	cfg := u.cfg
+	u.lmu.Unlock()
+	p, err := openSerialPort(cfg)
 	if err != nil {
-		return fmt.Errorf("open UART %s failed: %w", u.cfg.Device, err)
+		return fmt.Errorf("open UART %s failed: %w", cfg.DeviceLabel(), err)
 	}
+	u.lmu.Lock()
 	u.handle = p
+	u.lmu.Unlock()
 	return nil
 }
 
+// raw 返回当前句柄，供读循环、调制解调器监视、计数轮询等并发访问
+func (u *UARTPort) raw() rawPort {
+	u.lmu.Lock()
+	defer u.lmu.Unlock()
+	return u.handle
+}
+
 // Close 关闭串口
 func (u *UARTPort) Close() error {
-	if u.handle != nil {
-		return u.handle.Close()
+	if h := u.raw(); h != nil {
+		return h.Close()
 	}
 	return nil
 }
 
 // Read 实现 io.Reader，读取原始字节
 func (u *UARTPort) Read(p []byte) (int, error) {
-	return u.handle.Read(p)
+	return u.echo.read(p, u.readDecoded)
+}
+
+// readDecoded 读取并解码 BREAK 标记
+func (u *UARTPort) readDecoded(p []byte) (int, error) {
+	return u.brk.read(p, u.raw().Read)
 }
 
 // Write 实现 io.Writer
@@ -53,8 +69,10 @@ func (u *UARTPort) Write(p []byte) (int, error) {
 	fmt.Printf("⇨ UARTPort.Write writing %d bytes: % X (as string: %q)\n", len(p), p, string(p))
 
 	// 真正写入底层串口
-	n, err := u.handle.Write(p)
+	u.echo.expect(p)
+	n, err := u.raw().Write(p)
 	if err != nil {
+		u.echo.cancel()
 		return n, fmt.Errorf("UART write failed: %w", err)
 	}
 	return n, nil
@@ -62,7 +80,7 @@ func (u *UARTPort) Write(p []byte) (int, error) {
 
 // Name 返回逻辑名称
 func (u *UARTPort) Name() string {
-	return u.cfg.Name
+	return u.name
 }
 
 // ReadFrame 按最简单策略把一次 Read 当作一帧返回
@@ -86,3 +104,53 @@ func (u *UARTPort) WriteFrame(frame []byte) error {
 	}
 	return nil
 }
+
+// ModemLines 读取调制解调器控制/状态线
+func (u *UARTPort) ModemLines() (ModemLine, error) {
+	return getModemLines(u.raw().Fd())
+}
+
+// SetModemLines 设置 DTR/RTS
+func (u *UARTPort) SetModemLines(mask ModemLine, on bool) error {
+	return setModemLines(u.raw().Fd(), mask, on)
+}
+
+// WaitModemChange 等待输入线变化
+func (u *UARTPort) WaitModemChange(mask ModemLine) error {
+	return waitModemChange(u.raw().Fd(), mask)
+}
+
+// SendBreak 发送 BREAK
+func (u *UARTPort) SendBreak(d time.Duration) error {
+	return sendBreak(u.raw().Fd(), d)
+}
+
+// Counters 读取驱动的收发与错误计数
+func (u *UARTPort) Counters() (Counters, error) {
+	return readCounters(u.raw().Fd(), u.echo)
+}
+
+// Probe 检查设备是否仍然存在
+func (u *UARTPort) Probe() error {
+	return probeTTY(u.raw().Fd())
+}
+
+// LineSettings 返回当前线路参数
+func (u *UARTPort) LineSettings() LineSettings {
+	u.lmu.Lock()
+	defer u.lmu.Unlock()
+	return lineSettingsOf(u.cfg)
+}
+
+// SetLineSettings 在运行时修改线路参数
+func (u *UARTPort) SetLineSettings(ls LineSettings) error {
+	u.lmu.Lock()
+	defer u.lmu.Unlock()
+	cfg, err := reconfigure(u.handle, u.cfg, ls)
+	if err != nil {
+		return err
+	}
+	u.cfg = cfg
+	u.echo.reconfigured(cfg)
+	return nil
+}
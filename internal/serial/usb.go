@@ -0,0 +1,157 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// ErrNoDevice 表示当前没有满足 match 条件的 USB 串口（适配器未插入）
+var ErrNoDevice = errors.New("no USB tty matches")
+
+// TTYInfo 描述 sysfs 中一个 USB 串口及其所属的 USB 设备/接口
+type TTYInfo struct {
+	Name      string // tty 名称，如 ttyUSB0
+	Device    string // 设备节点，如 /dev/ttyUSB0
+	VID       string
+	PID       string
+	Serial    string
+	Interface int    // bInterfaceNumber，未知时为 -1
+	Path      string // USB 设备的总线路径，如 1-1.2
+	IfacePath string // USB 接口的总线路径，如 1-1.2:1.0
+}
+
+// SysfsResolver 遍历 /sys/class/tty/*/device，把 USB 匹配条件解析为设备节点。
+// Root 为 sysfs 所在的根目录，测试时可指向夹具目录（夹具内的符号链接应为相对路径，与真实 sysfs 一致）。
+type SysfsResolver struct {
+	Root   string // 默认 "/"
+	DevDir string // 设备节点目录，默认 /dev
+}
+
+// defaultResolver 用于打开/重新打开端口时解析 match
+var defaultResolver = SysfsResolver{}
+
+// List 列出所有挂在 USB 上的 tty
+func (r SysfsResolver) List() ([]TTYInfo, error) {
+	root := r.Root
+	if root == "" {
+		root = "/"
+	}
+	devDir := r.DevDir
+	if devDir == "" {
+		devDir = "/dev"
+	}
+	classDir := filepath.Join(root, "sys", "class", "tty")
+	entries, err := os.ReadDir(classDir)
+	if err != nil {
+		return nil, err
+	}
+	var out []TTYInfo
+	for _, e := range entries {
+		real, err := filepath.EvalSymlinks(filepath.Join(classDir, e.Name(), "device"))
+		if err != nil {
+			// 虚拟终端等没有 device 链接
+			continue
+		}
+		info, ok := usbInfo(real, root)
+		if !ok {
+			continue
+		}
+		info.Name = e.Name()
+		info.Device = filepath.Join(devDir, e.Name())
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// usbInfo 从 tty 的 device 目录向上查找 USB 接口（bInterfaceNumber）和 USB 设备（idVendor）
+func usbInfo(dir, root string) (TTYInfo, bool) {
+	info := TTYInfo{Interface: -1}
+	stop := filepath.Clean(root)
+	for d := filepath.Clean(dir); d != stop && d != filepath.Dir(d); d = filepath.Dir(d) {
+		if info.Interface < 0 {
+			if v, ok := readAttr(d, "bInterfaceNumber"); ok {
+				if n, err := strconv.ParseInt(v, 16, 32); err == nil {
+					info.Interface = int(n)
+					info.IfacePath = filepath.Base(d)
+				}
+			}
+		}
+		if vid, ok := readAttr(d, "idVendor"); ok {
+			info.VID = vid
+			info.PID, _ = readAttr(d, "idProduct")
+			info.Serial, _ = readAttr(d, "serial")
+			info.Path = filepath.Base(d)
+			return info, true
+		}
+	}
+	return info, false
+}
+
+// readAttr 读取 sysfs 属性文件并去掉换行
+func readAttr(dir, name string) (string, bool) {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
+
+// matches 判断 tty 是否满足所有已填写的匹配条件
+func (t TTYInfo) matches(m *config.USBMatch) bool {
+	if m.VID != "" && !strings.EqualFold(m.VID, t.VID) {
+		return false
+	}
+	if m.PID != "" && !strings.EqualFold(m.PID, t.PID) {
+		return false
+	}
+	if m.Serial != "" && m.Serial != t.Serial {
+		return false
+	}
+	if m.Interface != nil && *m.Interface != t.Interface {
+		return false
+	}
+	if m.Path != "" && m.Path != t.Path && m.Path != t.IfacePath {
+		return false
+	}
+	return true
+}
+
+// Resolve 返回唯一满足条件的设备节点；没有或多于一个匹配时报错
+func (r SysfsResolver) Resolve(m *config.USBMatch) (string, error) {
+	ttys, err := r.List()
+	if err != nil {
+		return "", fmt.Errorf("list tty devices: %w", err)
+	}
+	var found []TTYInfo
+	for _, t := range ttys {
+		if t.matches(m) {
+			found = append(found, t)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("%w %s", ErrNoDevice, m)
+	case 1:
+		return found[0].Device, nil
+	default:
+		names := make([]string, 0, len(found))
+		for _, t := range found {
+			names = append(names, fmt.Sprintf("%s(path=%s,interface=%d,serial=%q)", t.Name, t.Path, t.Interface, t.Serial))
+		}
+		return "", fmt.Errorf("USB match %s is ambiguous: %s", m, strings.Join(names, ", "))
+	}
+}
+
+// resolveDevice 返回端口当前应打开的设备节点：配置了 match 时按 sysfs 解析，否则直接使用 device
+func resolveDevice(cfg config.Port) (string, error) {
+	if cfg.Match == nil {
+		return cfg.Device, nil
+	}
+	return defaultResolver.Resolve(cfg.Match)
+}
@@ -0,0 +1,97 @@
+package serial
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/linjuya-lu/device_uart_go/internal/config"
+)
+
+// fixtureResolver 指向 testdata/sysfs：
+//
+//	ttyUSB0        0403:6001 serial=A10K3XYZ 1-1.2 接口 0
+//	ttyUSB1/2      0403:6010 serial=FT2232A  1-1.3 接口 0/1（双口适配器）
+//	ttyUSB3/4      1a86:7523 无序列号        1-1.4 / 1-1.5
+//	ttyS0          平台串口（不在 USB 上），tty0 无 device 链接
+var fixtureResolver = SysfsResolver{Root: "testdata/sysfs", DevDir: "/dev"}
+
+func intPtr(v int) *int { return &v }
+
+func TestSysfsList(t *testing.T) {
+	ttys, err := fixtureResolver.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]TTYInfo, len(ttys))
+	for _, tty := range ttys {
+		got[tty.Name] = tty
+	}
+	if len(got) != 5 {
+		t.Fatalf("listed %d USB ttys, want 5: %v", len(got), ttys)
+	}
+	want := TTYInfo{Name: "ttyUSB2", Device: "/dev/ttyUSB2", VID: "0403", PID: "6010", Serial: "FT2232A",
+		Interface: 1, Path: "1-1.3", IfacePath: "1-1.3:1.1"}
+	if got["ttyUSB2"] != want {
+		t.Fatalf("ttyUSB2 = %+v, want %+v", got["ttyUSB2"], want)
+	}
+}
+
+func TestSysfsResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		match config.USBMatch
+		want  string
+	}{
+		{"vid+pid", config.USBMatch{VID: "0403", PID: "6001"}, "/dev/ttyUSB0"},
+		{"vid case-insensitive", config.USBMatch{VID: "1A86", PID: "7523", Path: "1-1.5"}, "/dev/ttyUSB4"},
+		{"serial", config.USBMatch{Serial: "A10K3XYZ"}, "/dev/ttyUSB0"},
+		{"serial+interface", config.USBMatch{Serial: "FT2232A", Interface: intPtr(1)}, "/dev/ttyUSB2"},
+		{"interface 0", config.USBMatch{VID: "0403", PID: "6010", Interface: intPtr(0)}, "/dev/ttyUSB1"},
+		{"device path", config.USBMatch{Path: "1-1.4"}, "/dev/ttyUSB3"},
+		{"interface path", config.USBMatch{Path: "1-1.3:1.1"}, "/dev/ttyUSB2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dev, err := fixtureResolver.Resolve(&tc.match)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dev != tc.want {
+				t.Fatalf("Resolve(%s) = %s, want %s", &tc.match, dev, tc.want)
+			}
+		})
+	}
+}
+
+func TestSysfsResolveNoMatch(t *testing.T) {
+	for _, m := range []config.USBMatch{
+		{VID: "067b", PID: "2303"},
+		{Serial: "A10K3XYZ", Interface: intPtr(1)},
+		{Path: "2-1"},
+	} {
+		_, err := fixtureResolver.Resolve(&m)
+		if !errors.Is(err, ErrNoDevice) {
+			t.Fatalf("Resolve(%s) error = %v, want ErrNoDevice", &m, err)
+		}
+	}
+}
+
+func TestSysfsResolveAmbiguous(t *testing.T) {
+	for _, tc := range []struct {
+		match config.USBMatch
+		ttys  []string
+	}{
+		{config.USBMatch{VID: "1a86", PID: "7523"}, []string{"ttyUSB3", "ttyUSB4"}},
+		{config.USBMatch{Serial: "FT2232A"}, []string{"ttyUSB1", "ttyUSB2"}},
+	} {
+		_, err := fixtureResolver.Resolve(&tc.match)
+		if err == nil || errors.Is(err, ErrNoDevice) || !strings.Contains(err.Error(), "ambiguous") {
+			t.Fatalf("Resolve(%s) error = %v, want ambiguity", &tc.match, err)
+		}
+		for _, name := range tc.ttys {
+			if !strings.Contains(err.Error(), name) {
+				t.Fatalf("ambiguity error %q does not name %s", err, name)
+			}
+		}
+	}
+}